@@ -2,7 +2,10 @@ package vconfig
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -14,59 +17,242 @@ type BindFlag struct {
 	Flag    *pflag.Flag
 	BindKey string
 	value   interface{}
+	source  Source      // Where value came from, Set or a flag.
+	flagged bool        // viper has the flag's value from ApplyFromFlags.
+	applied interface{} // The value ApplyFromFlags gave viper.
+
+	flags       []*pflag.Flag // Every flag bound to the key, Flag is the last bound.
+	valueFrom   string        // The flag value was captured from, if it was.
+	appliedFrom string        // The flag applied came from.
+
+	// What viper had before the binding first changed it, for Unbind.
+	touched                 bool
+	before, defBefore       interface{}
+	hadBefore, hadDefBefore bool
 }
 type bindMap map[string]*BindFlag
 
-var bfm = make(bindMap) // Keyed by flag
-var bbm = make(bindMap) // keyed by binding
+// Binder keeps a set of bindings between flags and viper keys
+// along with the viper instance they get applied to.
+// Separate Binders don't share bindings, so different parts
+// of an application can each manage their own.
+//
+// A Binder is safe for concurrent use, as long as the viper
+// instance is only changed through the Binder.
+type Binder struct {
+	mu  sync.Mutex // guards the maps, the BindFlag values and viper.
+	v   *viper.Viper
+	bfm bindMap                   // Keyed by flag
+	bbm bindMap                   // keyed by binding
+	bpm map[*pflag.Flag]*BindFlag // Keyed by the flag itself, as names can be reused.
+
+	opts      *options      // From the last Init.
+	discovery Discovery     // From the last Init.
+	layers    []loadedLayer // Config files read, lowest precedence first.
+	includes  []string      // Globs from include, see include.go.
+	watcher   *watcher      // Set while watching the config files.
+
+	// The profile, see profile.go.
+	profile      string   // In use.
+	profileFiles []string // Profile files looked for, which may not exist.
+	profileNames []string // Found in the config files.
+
+	// Values with references in them, expanded, see interpolate.go.
+	expanded map[string]expansion
+
+	// Secrets, see secret.go.
+	secrets     map[string]bool // Keys from MarkSecret.
+	secretMu    sync.Mutex      // guards secretCache, never held while taking mu.
+	secretCache map[string]string
+
+	// What viper can't tell us about precedence, see explain.go.
+	defaults map[string]interface{} // From SetDefault, and the flag defaults from ApplyFromFlags.
+	sets     map[string]interface{} // Set values for keys that aren't bound.
+
+	// The environment, see env.go.
+	envs      map[string][]string    // Names from BindEnv.
+	envValues map[string]interface{} // Values read, which viper has when there's no override.
+
+	// Viper's override layer, which it doesn't let us look at, see overrides.go.
+	overrides map[string]interface{}
+	frames    []*flagFrame // From PushFlags, the last on top.
+
+	// Sets and Toggles that can be undone, see journal.go.
+	journal    []JournalEntry
+	journalPos int // journal[:journalPos] have been made, the rest undone.
+
+	// Copy of the hotKeys values, so they can be read without taking the lock.
+	snap atomic.Value // snapshot
+
+	// Change subscriptions, see events.go.
+	evMu       sync.Mutex // guards the fields below, never held while calling a subscriber.
+	subs       []subscription
+	nextSub    int
+	queue      []ChangeEvent // Waiting to be delivered.
+	delivering bool
+}
+
+// snapshot holds the values of hotKeys as last read from viper.
+// It is never modified once stored.
+type snapshot map[string]interface{}
+
+// hotKeys are read on nearly every call, so they are kept in the snapshot.
+var hotKeys = []string{DebugKey, VerboseKey}
+
+// NewBinder returns a Binder with no bindings that applies values to v.
+// If v is nil the Binder uses the global viper instance.
+func NewBinder(v *viper.Viper) *Binder {
+	b := &Binder{
+		v:        v,
+		bfm:      make(bindMap),
+		bbm:      make(bindMap),
+		bpm:      make(map[*pflag.Flag]*BindFlag),
+		defaults: make(map[string]interface{}),
+		sets:     make(map[string]interface{}),
+
+		envs:      make(map[string][]string),
+		envValues: make(map[string]interface{}),
+
+		overrides: make(map[string]interface{}),
+		secrets:   make(map[string]bool),
+	}
+	b.snap.Store(make(snapshot))
+	return b
+}
+
+// std is the Binder used by the package level functions.
+var std = NewBinder(nil)
+
+// DefaultBinder returns the Binder used by the package level functions.
+func DefaultBinder() *Binder {
+	return std
+}
+
+// Viper returns the viper instance that the Binder applies values to.
+func (b *Binder) Viper() *viper.Viper {
+	if b.v != nil {
+		return b.v
+	}
+	return viper.GetViper()
+}
 
 // Bind a flag key to a flag variable.
 // The reason we're doing this is because the viper
 // pFlags integration doesn't doesn't comprehend multiple
 // invocations. So, the first a key is bound to a flag,
 // a new BindEntry is created. After that if a bind entry already
-// exists, only the flag will be updated.
+// exists, the flag is added to it.
+//
+// A key can be bound to any number of flags, e.g. --filename on
+// each of several sub-commands' flag sets, or --file in one and
+// --filename in another. ApplyFromFlags uses the ones in the flag set
+// it's given. Binding a flag to a new key moves it from the old one.
 func Bind(bk string, f *pflag.Flag) (bf *BindFlag) {
-	if Debug() {
+	return std.Bind(bk, f)
+}
+
+// Bind a flag key to a flag variable in this Binder.
+// See the package level Bind.
+func (b *Binder) Bind(bk string, f *pflag.Flag) (bf *BindFlag) {
+	if b.Debug() {
 		pef()
 		defer pxf()
 	}
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bind(bk, f)
+}
 
-	// Always do both maps.
-	// Create a new key if both have no values in the maps.
-	// Else update the key that was found.
-	var ok bool
-	if bf, ok = bfm[f.Name]; !ok {
-		if bf, ok = bbm[bk]; !ok { // Neither have been set
-			if Debug() {
-				fmt.Printf("New binding: %#v\n", bk)
-			}
-			bf = new(BindFlag)
-		} else { // Out of Sync: bfm not set, bbm set.
-			if Debug() {
-				fmt.Printf("BindMaps are out of sync: keyed by flag missing, keyed by bind found: %#v\n",
-					bf)
+// BindE binds a flag key to a flag variable like Bind, but returns an error
+// rather than moving a flag that's already bound to another key.
+// Nothing is changed when there's an error.
+func BindE(bk string, f *pflag.Flag) (*BindFlag, error) {
+	return std.BindE(bk, f)
+}
+
+// BindE binds a flag key to a flag variable in this Binder, or returns an error.
+// See the package level BindE.
+func (b *Binder) BindE(bk string, f *pflag.Flag) (*BindFlag, error) {
+	if b.Debug() {
+		pef()
+		defer pxf()
+	}
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case f == nil:
+		return nil, &BindError{Key: bk}
+	case bk == "":
+		return nil, &BindError{Flag: f.Name}
+	}
+	if old, bound := b.bpm[f]; bound && old.BindKey != bk {
+		return nil, &BindError{Key: bk, Flag: f.Name, BoundTo: old.BindKey}
+	}
+	return b.bind(bk, f), nil
+}
+
+// bind does the work for Bind. b.mu must be held.
+func (b *Binder) bind(bk string, f *pflag.Flag) *BindFlag {
+	bf, ok := b.bbm[bk]
+	if old, bound := b.bpm[f]; bound {
+		switch {
+		case old == bf: // Already bound.
+			bf.Flag = f
+			b.bfm[f.Name] = bf
+			return bf
+		case !ok && len(old.flags) == 1:
+			// Nothing else uses the old binding, so it becomes this key's.
+			if b.Debug() {
+				fmt.Printf("Moving binding %#v to %#v\n", old.BindKey, bk)
 			}
+			delete(b.bbm, old.BindKey)
+			old.BindKey = bk
+			b.bbm[bk] = old
+			b.bfm[f.Name] = old
+			return old
 		}
-	} else if _, ok := bbm[bk]; !ok { // Out of Sync: bfm set, bbm not set.
-		if Debug() {
-			fmt.Printf("BindMaps are out of sync: keyed by flag found, keyed by binding missing: %#v\n",
-				bf)
-		}
+		b.detach(old, f, false)
 	}
 
-	// Always do BOTH maps.
-	bf.BindKey = bk
+	if !ok {
+		if b.Debug() {
+			fmt.Printf("New binding: %#v\n", bk)
+		}
+		bf = &BindFlag{BindKey: bk}
+		b.bbm[bk] = bf
+	} else if b.Debug() {
+		fmt.Printf("Adding flag %#v to binding %#v\n", f.Name, bk)
+	}
 	bf.Flag = f
-	bfm[f.Name] = bf
-	bbm[bk] = bf
-
+	bf.flags = append(bf.flags, f)
+	if b.isSecret(bk) {
+		MarkFlagSecret(f)
+	}
+	b.bpm[f] = bf
+	b.bfm[f.Name] = bf
+	if !ok {
+		b.loadEnv(bk) // Now it has a type.
+	}
 	return bf
 }
 
 // GetBindFlags returns all the BindFlags registered.
 func GetBindFlags() (bfs []*BindFlag) {
-	for _, v := range bfm {
+	return std.GetBindFlags()
+}
+
+// GetBindFlags returns all the BindFlags registered with this Binder.
+func (b *Binder) GetBindFlags() (bfs []*BindFlag) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bindFlags()
+}
+
+func (b *Binder) bindFlags() (bfs []*BindFlag) {
+	for _, v := range b.bbm {
 		bfs = append(bfs, v)
 	}
 	return bfs
@@ -74,11 +260,72 @@ func GetBindFlags() (bfs []*BindFlag) {
 
 // Set will set the viper variable and keep the
 // value for later application during Apply.
-func Set(bk string, value interface{}) {
-	if bf, ok := bbm[bk]; ok {
+// If bk is bound to a flag, string values are converted to the flag's type,
+// as they would be from the command line, and an error is returned
+// if that's not possible.
+func Set(bk string, value interface{}) error {
+	return std.Set(bk, value)
+}
+
+// Set will set the viper variable and keep the
+// value for later application during Apply.
+// See the package level Set.
+// The change can be undone, see Undo.
+func (b *Binder) Set(bk string, value interface{}) error {
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.checkValue(bk, value); err != nil {
+		return err
+	}
+	if err := b.record(bk, value); err != nil {
+		return err
+	}
+	return b.followProfile()
+}
+
+func (b *Binder) set(bk string, value interface{}) error {
+	if bf, ok := b.bbm[bk]; ok {
+		v, err := toValue(value, bf.Flag.Value.Type())
+		if err != nil {
+			return b.redactErr(bk, err)
+		}
+		value = v
+		b.touch(bf)
 		bf.value = value
+		bf.source = SourceSet
+	} else if value == nil {
+		delete(b.sets, strings.ToLower(bk))
+	} else {
+		b.sets[strings.ToLower(bk)] = value
+	}
+	b.write(bk, value, SourceSet)
+	b.refresh()
+	return nil
+}
+
+// Toggle sets the boolean value at bk to its opposite and returns the new value.
+// See ToggleE.
+func (b *Binder) Toggle(bk string) bool {
+	v, _ := b.ToggleE(bk)
+	return v
+}
+
+// ToggleE sets the boolean value at bk to its opposite and returns the value at bk.
+// As with Set, the value is left alone if the new one doesn't fit the schema.
+// The error is also returned if the change couldn't be written to the journal.
+func (b *Binder) ToggleE(bk string) (bool, error) {
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v := b.Viper().GetBool(bk)
+	if err := b.checkValue(bk, !v); err != nil {
+		return v, err
 	}
-	viper.Set(bk, value)
+	if err := b.record(bk, !v); err != nil {
+		return b.Viper().GetBool(bk), err
+	}
+	return !v, nil
 }
 
 // UpdateChangedFlags will look at each binding
@@ -87,37 +334,71 @@ func Set(bk string, value interface{}) {
 // right after a parse of flags has acurred. You might then
 // immediately call Apply() to cause the viper variables to take this new value.
 // This is different behavior than ApplyFromFlags.
-func UpdateChangedFlags() {
-	if Debug() {
+// Flags whose values can't be converted are reported in the error
+// and their bind values are left alone.
+func UpdateChangedFlags() error {
+	return std.UpdateChangedFlags()
+}
+
+// UpdateChangedFlags captures changed flag values as bind values.
+// See the package level UpdateChangedFlags.
+func (b *Binder) UpdateChangedFlags() error {
+	if b.Debug() {
 		pef()
 		defer pxf()
 	}
-	for _, bf := range GetBindFlags() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var errs errorList
+	for _, bf := range b.bindFlags() {
 		if bf.Flag.Changed {
-			if Debug() {
-				fmt.Printf("Flag changed %q, setting bind value to: %q\n", bf.Flag.Name, bf.Flag.Value.String())
+			if b.Debug() {
+				fmt.Printf("Flag changed %q, setting bind value to: %q\n", bf.Flag.Name, flagText(bf.Flag, b.isSecret(bf.BindKey)))
 			}
-			bf.setValueFrom(bf.Flag)
+			errs = errs.add(b.redactErr(bf.BindKey, bf.setValueFrom(bf.Flag)))
 		}
 	}
+	return errs.err()
 }
 
 // Apply will set the viper variable with BindKey to the Value if
 // there is a Value.
 func Apply() {
-	if Debug() {
+	std.Apply()
+}
+
+// Apply will set the viper variable with BindKey to the Value if
+// there is a Value.
+func (b *Binder) Apply() {
+	if b.Debug() {
 		pef()
 		defer pxf()
 	}
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.refresh()
+	b.apply()
+	b.tryFollowProfile()
+}
 
-	for _, bf := range bbm {
+// apply does the work of Apply, b.mu must be held.
+func (b *Binder) apply() {
+	for _, bk := range b.sortedBindKeys() {
+		bf := b.bbm[bk]
+		if bf.value != nil || bf.flagged {
+			b.touch(bf)
+		}
 		if bf.value != nil {
-			if Debug() {
+			if b.Debug() {
 				fmt.Printf("Setting viper value with key %#v with value %#v\n",
-					bf.BindKey, bf.value)
+					bf.BindKey, b.redact(bf.BindKey, bf.value))
 			}
-			viper.Set(bf.BindKey, bf.value)
+			b.write(bf.BindKey, bf.value, bf.source)
+		} else if bf.flagged {
+			b.clearFlagged(bf)
 		}
+		bf.flagged = false
 	}
 }
 
@@ -126,96 +407,452 @@ func Apply() {
 // set the viper value to the value of the flag (not the BindValue).
 // This is where precedence is maintained essentially allowing for
 // a switch having flags take short-term preccedence over sets.
-func ApplyFromFlags(pflags *pflag.FlagSet) {
-	if Debug() {
+// Flags whose values can't be converted are reported in the error
+// and don't change viper.
+func ApplyFromFlags(pflags *pflag.FlagSet) error {
+	return std.ApplyFromFlags(pflags)
+}
+
+// ApplyFromFlags sets viper values from the changed flags in pflags.
+// See the package level ApplyFromFlags.
+func (b *Binder) ApplyFromFlags(pflags *pflag.FlagSet) error {
+	if b.Debug() {
 		pef()
 		defer pxf()
 	}
-	pflags.VisitAll(func(pf *pflag.Flag) {
-		if Debug() {
-			fmt.Printf("Visiting flag: %#v\n%s", pf.Name, flagString(pf))
-		}
-		if bf := bfm[pf.Name]; bf != nil { // if bound
-			var v interface{}
-			if pf.Changed { // and flag changed
-				// Set the viper variable to the flag value.
-				v = flagValue(pf)
-				// Since we're going to set a viper value from a flag
-				// we need to make sure there is a bind value to
-				// replace it with at a later time through Apply.
-				// So, check to see what the current value is,
-				// and if there is no, use the default.
-				// TODO: THIS BREAKS ANYMORE DYNAMIC UPDATES.
-				// So if you're watching a config file and/or you're
-				// using an environment variable those won't effect subsequent
-				// viper queires of this value.
-				if bf.value == nil { // If there is a current value use that.
-					if viper.IsSet(bf.BindKey) {
-						bf.value = viper.Get(bf.BindKey)
-					} else {
-						fdv := flagDefValue(pf) // otherwise use the default.
-						bf.value = fdv
-					}
-				}
-			} else if bf.value != nil { // or not changed and we have a bind value
-				v = bf.value
-			} // we don't care about the case where we're not changing by a flag and there is no bind value.
-			// If we've set a viper value give it viper.
-			if v != nil {
-				if Debug() {
-					fmt.Printf("Setting viper value %#v to %#v\n", bf.BindKey, v)
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.refresh()
+
+	vp := b.Viper()
+	bfs, values, errs := b.flagSetValues(pflags)
+	for _, bf := range bfs {
+		b.touch(bf)
+		var v interface{}
+		src := bf.source
+		if fc, ok := values[bf]; ok { // a flag changed
+			// Set the viper variable to the flag value.
+			v = fc.value
+			// Since we're going to set a viper value from a flag
+			// we need to be able to undo it at a later time through Apply.
+			// We don't keep the current value to put back, as that would
+			// stop changes from a config file reload or the environment
+			// showing through afterwards. Instead we remember that the flag
+			// was applied, and make sure the flag default is there
+			// to fall back on if nothing else is set.
+			if bf.value == nil && !vp.IsSet(bf.BindKey) {
+				fdv, err := flagDefValue(fc.flag)
+				if errs = errs.add(b.redactErr(bf.BindKey, err)); err == nil {
+					vp.SetDefault(bf.BindKey, fdv)
+					b.defaults[strings.ToLower(bf.BindKey)] = fdv
 				}
-				viper.Set(bf.BindKey, v)
 			}
+			bf.flagged = true
+			bf.applied = v
+			bf.appliedFrom = fc.flag.Name
+			src = SourceFlag
+		} else if bf.value != nil { // or not changed and we have a bind value
+			v = bf.value
+		} else if bf.flagged { // or not changed and a previous flag is still in place.
+			b.clearFlagged(bf)
+		} // we don't care about the case where we're not changing by a flag and there is no bind value.
+		// If we've set a viper value give it viper.
+		if v != nil {
+			if b.Debug() {
+				fmt.Printf("Setting viper value %#v to %#v\n", bf.BindKey, b.redact(bf.BindKey, v))
+			}
+			b.write(bf.BindKey, v, src)
+		}
+	}
+	errs = errs.add(b.followProfile())
+	return errs.add(b.interpolate()).err()
+}
+
+// flagChange is the value from a changed flag.
+type flagChange struct {
+	flag  *pflag.Flag
+	value interface{}
+}
+
+// flagSetValues finds the bindings for the flags in pflags, in the order they're visited,
+// and the value for each from its flags that changed. Bindings for flags that can't
+// be converted, or that were given different values by different flags, are left out
+// and reported in the errors. b.mu must be held.
+func (b *Binder) flagSetValues(pflags *pflag.FlagSet) (bfs []*BindFlag, values map[*BindFlag]flagChange, errs errorList) {
+	values = make(map[*BindFlag]flagChange)
+	seen := make(map[*BindFlag]bool)
+	bad := make(map[*BindFlag]bool)
+	conflicts := make(map[*BindFlag]*FlagConflictError)
+	pflags.VisitAll(func(pf *pflag.Flag) {
+		// The flag may not be the one bound, so whether it's secret comes from the binding.
+		bf := b.flagBinding(pf)
+		if b.Debug() {
+			fmt.Printf("Visiting flag: %#v\n%s", pf.Name, flagString(pf, bf != nil && b.isSecret(bf.BindKey)))
+		}
+		if bf == nil { // not bound
+			return
+		}
+		if !seen[bf] {
+			seen[bf] = true
+			bfs = append(bfs, bf)
+		}
+		if !pf.Changed {
+			return
+		}
+		v, err := flagValue(pf)
+		if err != nil {
+			errs = errs.add(b.redactErr(bf.BindKey, err))
+			bad[bf] = true
+			return
+		}
+		fc, ok := values[bf]
+		switch {
+		case !ok:
+			values[bf] = flagChange{flag: pf, value: v}
+		case conflicts[bf] != nil:
+			conflicts[bf].add(pf)
+		case !reflect.DeepEqual(fc.value, v):
+			ce := &FlagConflictError{Key: bf.BindKey}
+			ce.add(fc.flag)
+			ce.add(pf)
+			b.redactErr(bf.BindKey, ce)
+			conflicts[bf] = ce
+			errs = errs.add(ce)
+			bad[bf] = true
 		}
 	})
+	if len(bad) > 0 {
+		good := bfs[:0]
+		for _, bf := range bfs {
+			if bad[bf] {
+				delete(values, bf)
+			} else {
+				good = append(good, bf)
+			}
+		}
+		bfs = good
+	}
+	return bfs, values, errs
+}
+
+// flagBinding returns the binding for pf.
+// A flag that isn't bound itself uses the binding for its name, as
+// happens when a flag set is made again with the same flags, e.g. for
+// each command in a REPL. b.mu must be held.
+func (b *Binder) flagBinding(pf *pflag.Flag) *BindFlag {
+	if bf := b.bpm[pf]; bf != nil {
+		return bf
+	}
+	return b.bfm[pf.Name]
 }
 
 // ResetBindings will erase existing bindings.
-// This is really used for Testing.
+// This is really used for Testing. Reset erases the rest as well.
 func ResetBindings() {
-	bfm = make(bindMap)
-	bbm = make(bindMap)
+	std.ResetBindings()
 }
 
-// GetBindFlagFor return BindFlag for the flag key.
-func getBindFlagFor(fk string) *BindFlag {
-	return bfm[fk]
+// ResetBindings will erase the Binder's existing bindings.
+func (b *Binder) ResetBindings() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bfm = make(bindMap)
+	b.bbm = make(bindMap)
+	b.bpm = make(map[*pflag.Flag]*BindFlag)
+	b.refresh()
 }
 
-// SetValueFrom sets the VindFLag value from a pfFlag.
-// a flag.
-func (bf *BindFlag) setValueFrom(f *pflag.Flag) {
-	bf.value = flagValue(f)
+// Reset erases the bindings, and what's kept about the values in viper,
+// to go with viper.Reset. This is really used for Testing.
+func Reset() {
+	std.Reset()
 }
 
-// This is gratuitous and only used in test.
-func flagForFlagKey(fk string) (bf *BindFlag, ok bool) {
-	bf, ok = bfm[fk]
-	return bf, ok
+// Reset erases the Binder's bindings and what it keeps about the values in
+// its viper: Sets, defaults, the environment, pushed flags, the journal,
+// secrets, and the config files read, which are no longer watched.
+// Change subscriptions are kept.
+// It goes with making the viper instance new, e.g. with viper.Reset.
+func (b *Binder) Reset() {
+	b.StopWatch()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bfm = make(bindMap)
+	b.bbm = make(bindMap)
+	b.bpm = make(map[*pflag.Flag]*BindFlag)
+	b.opts, b.discovery, b.layers, b.includes = nil, Discovery{}, nil, nil
+	b.profile, b.profileFiles, b.profileNames = "", nil, nil
+	b.expanded = nil
+	b.secrets = make(map[string]bool)
+	b.defaults = make(map[string]interface{})
+	b.sets = make(map[string]interface{})
+	b.envs = make(map[string][]string)
+	b.envValues = make(map[string]interface{})
+	b.overrides = make(map[string]interface{})
+	b.frames = nil
+	b.journal, b.journalPos = nil, 0
+	b.ForgetSecrets()
+	b.refresh()
 }
 
-func flagValue(f *pflag.Flag) interface{} {
-	return stringValue(f.Value.String(), f.Value.Type())
+// Unbind removes the binding for the bind key bk, returning false if there isn't one.
+// If restore is true the viper value (and default) from before the binding
+// was first applied is put back. Otherwise the value is left, and a value
+// from Set is kept as though the key had never been bound.
+func Unbind(bk string, restore bool) bool {
+	return std.Unbind(bk, restore)
 }
 
-func flagDefValue(f *pflag.Flag) interface{} {
-	t := f.Value.Type()
-	return stringValue(f.DefValue, t)
+// Unbind removes the binding for the bind key bk.
+// See the package level Unbind.
+func (b *Binder) Unbind(bk string, restore bool) bool {
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.refresh()
+	bf, ok := b.bbm[bk]
+	if ok {
+		b.unbind(bf, restore)
+	}
+	return ok
+}
+
+// UnbindFlag removes the flags named name from the binding that has the most recently bound of them,
+// returning false if there isn't one. Once a binding has no flags left it's removed,
+// see Unbind for restore.
+func UnbindFlag(name string, restore bool) bool {
+	return std.UnbindFlag(name, restore)
 }
 
-// TODO: Need to do one for int and float.
-func stringValue(v, t string) interface{} {
-	switch t {
-	case "string":
-		return v
-	case "bool":
-		if strings.ToLower(v) == "true" {
-			return true
+// UnbindFlag removes the binding for the flag named name.
+// See the package level UnbindFlag.
+func (b *Binder) UnbindFlag(name string, restore bool) bool {
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.refresh()
+	bf, ok := b.bfm[name]
+	if ok {
+		for _, f := range append([]*pflag.Flag(nil), bf.flags...) {
+			if f.Name == name {
+				b.detach(bf, f, restore)
+			}
 		}
-		return false
-	default:
-		return v
 	}
+	return ok
+}
 
+// UnbindFlagSet removes the bindings for the flags in pflags, returning how many flags there were.
+// Once a binding has no flags left it's removed, see Unbind for restore.
+func UnbindFlagSet(pflags *pflag.FlagSet, restore bool) int {
+	return std.UnbindFlagSet(pflags, restore)
+}
+
+// UnbindFlagSet removes the bindings for the flags in pflags.
+// See the package level UnbindFlagSet.
+func (b *Binder) UnbindFlagSet(pflags *pflag.FlagSet, restore bool) (n int) {
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.refresh()
+	pflags.VisitAll(func(pf *pflag.Flag) {
+		// Only if it's bound to this flag, not another with the same name.
+		if bf, ok := b.bpm[pf]; ok {
+			b.detach(bf, pf, restore)
+			n++
+		}
+	})
+	return n
+}
+
+// detach removes the flag f from bf, and bf if that was its last flag.
+// b.mu must be held.
+func (b *Binder) detach(bf *BindFlag, f *pflag.Flag, restore bool) {
+	for i, bff := range bf.flags {
+		if bff == f {
+			bf.flags = append(bf.flags[:i:i], bf.flags[i+1:]...)
+			break
+		}
+	}
+	delete(b.bpm, f)
+	if len(bf.flags) == 0 {
+		b.unbind(bf, restore)
+		return
+	}
+	bf.Flag = bf.flags[len(bf.flags)-1]
+	if b.bfm[f.Name] == bf {
+		b.indexName(f.Name)
+	}
+}
+
+// indexName points bfm at the last binding with a flag named name, if there is one.
+// b.mu must be held.
+func (b *Binder) indexName(name string) {
+	delete(b.bfm, name)
+	for _, bk := range b.sortedBindKeys() {
+		for _, f := range b.bbm[bk].flags {
+			if f.Name == name {
+				b.bfm[name] = b.bbm[bk]
+			}
+		}
+	}
+}
+
+// unbind removes bf from the maps. b.mu must be held.
+func (b *Binder) unbind(bf *BindFlag, restore bool) {
+	if b.Debug() {
+		fmt.Printf("Unbinding %#v\n", bf.BindKey)
+	}
+	if b.bbm[bf.BindKey] == bf {
+		delete(b.bbm, bf.BindKey)
+	}
+	for _, f := range bf.flags {
+		delete(b.bpm, f)
+	}
+	var names []string
+	for name, v := range b.bfm {
+		if v == bf {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		b.indexName(name)
+	}
+
+	key := strings.ToLower(bf.BindKey)
+	if !restore {
+		if bf.value != nil && bf.source == SourceSet {
+			b.sets[key] = bf.value
+		}
+		return
+	}
+	if !bf.touched {
+		return
+	}
+	v := b.Viper()
+	old := v.Get(key)
+	if bf.hadDefBefore {
+		v.SetDefault(key, bf.defBefore)
+		b.defaults[key] = bf.defBefore
+	} else if _, ok := b.defaults[key]; ok {
+		// Viper ignores nil defaults.
+		v.SetDefault(key, nil)
+		delete(b.defaults, key)
+	}
+	b.setOverride(key, bf.before) // nil if there wasn't one.
+	b.changed(key, old, v.Get(key), b.sourceOf(key))
+}
+
+// touch records what viper has for bf's key before the binding first changes it.
+// b.mu must be held.
+func (b *Binder) touch(bf *BindFlag) {
+	if bf.touched {
+		return
+	}
+	key := strings.ToLower(bf.BindKey)
+	bf.touched = true
+	bf.before, bf.hadBefore = b.overrides[key]
+	bf.defBefore, bf.hadDefBefore = b.defaults[key]
+	if !bf.hadBefore {
+		// A value we can't account for was put in viper directly, e.g. with viper.Set.
+		v := b.Viper()
+		if e := b.explain(key); v.IsSet(key) && (e.Winner < 0 || !reflect.DeepEqual(e.Candidates[e.Winner].Value, e.Value)) {
+			bf.before, bf.hadBefore = e.Value, true
+		}
+	}
+}
+
+// GetBindFlagFor return BindFlag for the flag key.
+func (b *Binder) getBindFlagFor(fk string) *BindFlag {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bfm[fk]
+}
+
+// Refresh picks up changes made to the viper instance outside of the Binder
+// for the values that are read without waiting for the lock (e.g. Debug()).
+// They're read from viper when the Binder isn't busy, so this is rarely needed.
+func (b *Binder) Refresh() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+}
+
+// refresh stores a new snapshot of the hotKeys.
+// Must be called with b.mu held.
+func (b *Binder) refresh() {
+	// Errors are reported by the calls that can return them, and GetE.
+	b.interpolate()
+	v := b.Viper()
+	s := make(snapshot, len(hotKeys))
+	for _, k := range hotKeys {
+		s[k] = v.Get(k)
+	}
+	b.snap.Store(s)
+}
+
+// hot returns the value of a hotKey. It never waits for the lock: when it's free
+// the value comes from viper, which may have been changed directly, and otherwise
+// from the last snapshot. So it can be called with b.mu held.
+func (b *Binder) hot(k string) interface{} {
+	s := b.snap.Load().(snapshot)
+	if !b.mu.TryLock() {
+		return s[k]
+	}
+	defer b.mu.Unlock()
+	v := b.Viper().Get(k)
+	if !reflect.DeepEqual(v, s[k]) {
+		ns := make(snapshot, len(s))
+		for hk, hv := range s {
+			ns[hk] = hv
+		}
+		ns[k] = v
+		b.snap.Store(ns)
+	}
+	return v
+}
+
+// Debug returns whether debug mode is set for this Binder.
+// It doesn't wait for the Binder lock.
+func (b *Binder) Debug() bool {
+	return DebugSetting.GetFrom(b)
+}
+
+// Verbose returns whether verbose mode is set for this Binder.
+// It doesn't wait for the Binder lock.
+func (b *Binder) Verbose() bool {
+	return VerboseSetting.GetFrom(b)
+}
+
+// SetValueFrom sets the VindFLag value from a pfFlag.
+// a flag. The value is left alone if the flag can't be converted.
+func (bf *BindFlag) setValueFrom(f *pflag.Flag) error {
+	v, err := flagValue(f)
+	if err != nil {
+		return err
+	}
+	bf.value = v
+	bf.source = SourceFlag
+	bf.valueFrom = f.Name
+	return nil
+}
+
+// clearFlagged removes the value ApplyFromFlags gave viper, so
+// the config, environment or default can be seen again.
+// b.mu must be held.
+func (b *Binder) clearFlagged(bf *BindFlag) {
+	if b.Debug() {
+		fmt.Printf("Clearing flag value for viper key %#v\n", bf.BindKey)
+	}
+	// Viper skips nil values in its override layer.
+	bf.flagged = false
+	bf.applied = nil
+	b.write(bf.BindKey, nil, SourceDefault)
+}
+
+// This is gratuitous and only used in test.
+func flagForFlagKey(fk string) (bf *BindFlag, ok bool) {
+	bf, ok = std.bfm[fk]
+	return bf, ok
 }
@@ -2,6 +2,10 @@
 package vconfig
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/spf13/pflag"
@@ -59,7 +63,7 @@ var s2 = set{key: "screen", value: "darkScreen"}
 
 // Reset envrionment before testing.
 func reset() {
-	ResetBindings()
+	Reset()
 	viper.Reset()
 }
 
@@ -89,7 +93,10 @@ func getChangedValues(flags []flag, pflags *pflag.FlagSet, t *testing.T) {
 		if pf := pflags.Lookup(f.fk); pf != nil {
 			if pf.Changed {
 				if bf, ok := flagForFlagKey(pf.Name); ok {
-					bf.value = flagValue(pf)
+					var err error
+					if bf.value, err = flagValue(pf); err != nil {
+						t.Errorf("Failed to convert flag %s: %v", pf.Name, err)
+					}
 					t.Logf("Changed flag Value is %#v", bf.value)
 				} else {
 					t.Errorf("Failed to find bind flag, for flag %s", pf.Name)
@@ -189,9 +196,9 @@ func TestParseWithBind(t *testing.T) {
 			getChangedValues(c.flags, pflags, t)
 
 			// Tests
-			if len(bfm) != c.mapLen || len(bbm) != c.mapLen {
+			if len(std.bfm) != c.mapLen || len(std.bbm) != c.mapLen {
 				t.Errorf("bindMap not the right size. Expected %d, got bfm = %d  and bbm = %d entries.",
-					c.mapLen, len(bfm), len(bbm))
+					c.mapLen, len(std.bfm), len(std.bbm))
 			}
 
 			for _, f := range c.flags {
@@ -199,21 +206,21 @@ func TestParseWithBind(t *testing.T) {
 				t.Logf("Checking flag: %s", f.fk)
 
 				// Make sure the two lookups (bfm, bbm), don't get out of sync.
-				if _, ok := bbm[f.bk]; ok {
+				if _, ok := std.bbm[f.bk]; ok {
 					if _, ok := flagForFlagKey(f.fk); !ok {
 						t.Errorf("The bind map and the flag map are out of sync: Bind has key, flag doesn't.")
-						t.Logf("Bind Map: %#v\n", bbm)
-						t.Logf("Flag Map: %#v\n", bfm)
+						t.Logf("Bind Map: %#v\n", std.bbm)
+						t.Logf("Flag Map: %#v\n", std.bfm)
 					}
 				} else {
 					if _, ok := flagForFlagKey(f.fk); ok {
 						t.Errorf("The bind map and the flag map are out of sync: Bind doesn't have key, flag does.")
-						t.Logf("Bind Map: %#v\n", bbm)
-						t.Logf("Flag Map: %#v\n", bfm)
+						t.Logf("Bind Map: %#v\n", std.bbm)
+						t.Logf("Flag Map: %#v\n", std.bfm)
 					} else {
 						t.Errorf("Neither the bind nor the flag maps got the expected keys.")
-						t.Logf("Bind Map: %#v\n", bbm)
-						t.Logf("Flag Map: %#v\n", bfm)
+						t.Logf("Bind Map: %#v\n", std.bbm)
+						t.Logf("Flag Map: %#v\n", std.bfm)
 					}
 				}
 
@@ -272,7 +279,7 @@ func TestBindSet(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			for _, s := range c.vSets {
 				// Check bind key.
-				if bk, ok := bbm[s.key]; ok {
+				if bk, ok := std.bbm[s.key]; ok {
 					bV := bk.value.(string)
 					if bV != s.value {
 						t.Errorf("Bound value mismatched after set. Got: %#v, expected %#v", bV, s.value)
@@ -529,8 +536,9 @@ func TestBindVerboseFirstInteractive(t *testing.T) {
 	pflags.Visit(func(pf *pflag.Flag) {
 		if pf.Name == f.fk {
 			visit = true
-			if bf := bfm[pf.Name]; bf != nil {
-				viper.Set(bf.BindKey, flagValue(pf))
+			if bf := std.bfm[pf.Name]; bf != nil {
+				fv, _ := flagValue(pf)
+				viper.Set(bf.BindKey, fv)
 			}
 		}
 	})
@@ -607,7 +615,7 @@ func TestMultipleBindsInteractiveLineFlag(t *testing.T) {
 
 	// Setup
 	reset()
-	if len(bbm) > 0 || len(bfm) > 0 {
+	if len(std.bbm) > 0 || len(std.bfm) > 0 {
 		t.Errorf("ResetBindings didn't empty the BindMaps")
 	}
 
@@ -686,7 +694,7 @@ func TestUpdateChangedFlags(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 
 			for _, f := range c.flags {
-				if bf, ok := bbm[f.bk]; ok {
+				if bf, ok := std.bbm[f.bk]; ok {
 					if bf.value != f.fv {
 						t.Errorf("BindValue for BindKey %q and FlagKey %q is inncorrect. Expected %#v, Got: %#v",
 							f.bk, f.fk, f.fv, bf.value)
@@ -815,3 +823,290 @@ func TestAPI(t *testing.T) {
 		})
 	}
 }
+
+func TestDebugAroundTheBinder(t *testing.T) {
+	reset()
+	defer reset()
+
+	// Debug and Verbose see viper changed directly, as they always have.
+	viper.Set(DebugKey, true)
+	if !Debug() {
+		t.Errorf("Debug should see a viper.Set.")
+	}
+	pflags := pflag.NewFlagSet("AroundTheBinder", pflag.ContinueOnError)
+	pflags.Bool(VerboseKey, false, "")
+	viper.BindPFlag(VerboseKey, pflags.Lookup(VerboseKey))
+	pflags.Parse([]string{"--verbose"})
+	if !Verbose() || !viper.GetBool(VerboseKey) {
+		t.Errorf("Verbose should see a flag bound with viper.BindPFlag.")
+	}
+}
+
+func TestBinderDebug(t *testing.T) {
+	reset()
+	defer reset()
+
+	// Each Binder traces by its own debug setting, not the package one.
+	b := NewBinder(viper.New())
+	b.Set(DebugKey, true)
+	pflags := pflag.NewFlagSet("BinderDebug", pflag.ContinueOnError)
+	pflags.String("name", "", "")
+	if out := stdout(t, func() { b.Bind("name", pflags.Lookup("name")) }); !strings.Contains(out, "Enter") {
+		t.Errorf("Expected a trace from a Binder with debug set, got: %q", out)
+	}
+
+	Set(DebugKey, true)
+	b.Set(DebugKey, false)
+	if out := stdout(t, func() { b.Bind("name", pflags.Lookup("name")) }); out != "" {
+		t.Errorf("Expected no trace from a Binder without debug set, got: %q", out)
+	}
+}
+
+func TestBinderInstances(t *testing.T) {
+
+	// Setup
+	reset()
+	b1 := NewBinder(viper.New())
+	b2 := NewBinder(viper.New())
+
+	pf1 := pflag.NewFlagSet("BinderOne", pflag.PanicOnError)
+	pf1.String(f1.fk, f1.fd, f1.fh)
+	b1.Bind(f1.bk, pf1.Lookup(f1.fk))
+
+	pf2 := pflag.NewFlagSet("BinderTwo", pflag.PanicOnError)
+	pf2.String(f1.fk, f1.fd, f1.fh)
+	b2.Bind(f1.bk, pf2.Lookup(f1.fk))
+
+	pf1.Parse([]string{"--" + f1.fk, f1.fv})
+	b1.UpdateChangedFlags()
+	b1.Apply()
+	b2.Set(s1.key, s1.value)
+
+	// Tests
+	if v := b1.Viper().GetString(f1.bk); v != f1.fv {
+		t.Errorf("First binder has the wrong value. Got: %#v, Expected: %#v", v, f1.fv)
+	}
+	if v := b2.Viper().GetString(s1.key); v != s1.value {
+		t.Errorf("Second binder has the wrong value. Got: %#v, Expected: %#v", v, s1.value)
+	}
+	if viper.IsSet(f1.bk) {
+		t.Errorf("Global viper should not have been touched by a Binder with its own viper: %#v", viper.Get(f1.bk))
+	}
+	if len(std.bbm) != 0 || len(std.bfm) != 0 {
+		t.Errorf("Default binder should not have picked up bindings. Got bfm = %d and bbm = %d entries.",
+			len(std.bfm), len(std.bbm))
+	}
+
+	b1.ResetBindings()
+	if len(b1.GetBindFlags()) != 0 || len(b2.GetBindFlags()) != 1 {
+		t.Errorf("ResetBindings should only clear its own Binder. Got %d and %d BindFlags.",
+			len(b1.GetBindFlags()), len(b2.GetBindFlags()))
+	}
+}
+
+// Run this with -race to have it mean anything.
+func TestConcurrentBindings(t *testing.T) {
+
+	// Setup
+	b := NewBinder(viper.New())
+	pflags := pflag.NewFlagSet("Concurrent", pflag.PanicOnError)
+	registerOn := func(f flag) {
+		pflags.String(f.fk, f.fd, f.fh)
+		b.Bind(f.bk, pflags.Lookup(f.fk))
+	}
+	registerOn(f1)
+	registerOn(f2)
+	pflags.Bool(VerboseKey, false, "verbose")
+	b.Bind(VerboseKey, pflags.Lookup(VerboseKey))
+	pflags.Parse([]string{"--" + f1.fk, f1.fv, "--" + VerboseKey})
+	b.UpdateChangedFlags()
+
+	const workers = 8
+	const loops = 100
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Each worker gets it's own flags to bind.
+			wflags := pflag.NewFlagSet(fmt.Sprintf("Worker-%d", i), pflag.PanicOnError)
+			fk := fmt.Sprintf("worker%d", i)
+			wflags.String(fk, "", "")
+			wflags.Parse([]string{"--" + fk, "value"})
+			for j := 0; j < loops; j++ {
+				switch j % 6 {
+				case 0:
+					b.Set(s2.key, fmt.Sprintf("%s-%d", s2.value, j))
+				case 1:
+					b.Apply()
+				case 2:
+					b.ApplyFromFlags(pflags)
+				case 3:
+					b.Bind(fk, wflags.Lookup(fk))
+					b.ApplyFromFlags(wflags)
+				case 4:
+					b.Toggle(DebugKey)
+					b.UpdateChangedFlags()
+				case 5:
+					b.GetBindFlags()
+				}
+				b.Debug()
+				b.Verbose()
+				Debug()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Tests
+	if v := b.Viper().GetString(f1.bk); v != f1.fv {
+		t.Errorf("Flag value lost during concurrent use. Got: %#v, Expected: %#v", v, f1.fv)
+	}
+	if !b.Verbose() {
+		t.Errorf("Verbose should be set from the flag after concurrent use.")
+	}
+	if b.Debug() != b.Viper().GetBool(DebugKey) {
+		t.Errorf("Debug snapshot out of date. Got: %t, viper has: %t", b.Debug(), b.Viper().GetBool(DebugKey))
+	}
+	if n := len(b.GetBindFlags()); n != 3+workers {
+		t.Errorf("Wrong number of BindFlags after concurrent binds. Got: %d, Expected: %d", n, 3+workers)
+	}
+}
+
+func TestUnbind(t *testing.T) {
+
+	// Setup
+	b := NewBinder(viper.New())
+	v := b.Viper()
+	v.SetDefault("screen", "default-screen")
+	b.SetDefault("screen", "default-screen")
+	pflags := pflag.NewFlagSet("Unbind", pflag.PanicOnError)
+	pflags.String("screen", "", "")
+	pflags.String("conn", "flag-default", "")
+	pflags.String("file", "", "")
+	pflags.String("name", "", "")
+	v.Set("name", "viper-name") // Around the Binder.
+	b.Bind("screen", pflags.Lookup("screen"))
+	b.Bind("connection", pflags.Lookup("conn"))
+	b.Bind("filename", pflags.Lookup("file"))
+	b.Bind("name", pflags.Lookup("name"))
+	b.Set("filename", "set-file")
+	pflags.Parse([]string{"--screen", "flag-screen", "--conn", "flag-conn", "--name", "flag-name"})
+	b.ApplyFromFlags(pflags)
+
+	// Without restore the value stays, with it we go back to before the binding.
+	type tc struct {
+		unbind  func() bool
+		key     string
+		ok      bool
+		e       interface{}
+		isSet   bool
+		eSource Source
+	}
+	cases := []tc{
+		{unbind: func() bool { return b.Unbind("filename", false) }, key: "filename", ok: true, e: "set-file", isSet: true, eSource: SourceSet},
+		{unbind: func() bool { return b.UnbindFlag("screen", true) }, key: "screen", ok: true, e: "default-screen", isSet: true, eSource: SourceDefault},
+		{unbind: func() bool { return b.Unbind("connection", true) }, key: "connection", ok: true, e: nil, isSet: false, eSource: SourceDefault},
+		{unbind: func() bool { return b.Unbind("connection", true) }, key: "connection", ok: false, e: nil, isSet: false, eSource: SourceDefault},
+		{unbind: func() bool { return b.Unbind("name", true) }, key: "name", ok: true, e: "viper-name", isSet: true, eSource: SourceDefault},
+	}
+	for i, c := range cases {
+		if ok := c.unbind(); ok != c.ok {
+			t.Errorf("Case %d: wrong result from unbind. Got: %#v, Expected: %#v", i, ok, c.ok)
+		}
+		if g := v.Get(c.key); g != c.e {
+			t.Errorf("Case %d: wrong value for %q. Got: %#v, Expected: %#v", i, c.key, g, c.e)
+		}
+		if s := v.IsSet(c.key); s != c.isSet {
+			t.Errorf("Case %d: wrong IsSet for %q. Got: %#v, Expected: %#v", i, c.key, s, c.isSet)
+		}
+		if s := b.Explain(c.key).Source(); s != c.eSource {
+			t.Errorf("Case %d: wrong source for %q. Got: %s, Expected: %s", i, c.key, s, c.eSource)
+		}
+	}
+	if len(b.bfm) != 0 || len(b.bbm) != 0 {
+		t.Errorf("Bindings left behind. Got bfm = %#v and bbm = %#v.", b.bfm, b.bbm)
+	}
+
+	// UnbindFlagSet only takes the bindings to its own flags, and any stale keys for them.
+	other := pflag.NewFlagSet("Other", pflag.PanicOnError)
+	other.String("screen", "", "")
+	b.Bind("screen", pflags.Lookup("screen"))
+	b.Bind("screen.name", pflags.Lookup("screen")) // Rebinding leaves "screen" in bbm.
+	if n := b.UnbindFlagSet(other, false); n != 0 {
+		t.Errorf("Unbound flags of another set. Got: %d, Expected: 0", n)
+	}
+	if n := b.UnbindFlagSet(pflags, false); n != 1 {
+		t.Errorf("Wrong number unbound. Got: %d, Expected: 1", n)
+	}
+	if len(b.bfm) != 0 || len(b.bbm) != 0 {
+		t.Errorf("Bindings left behind. Got bfm = %#v and bbm = %#v.", b.bfm, b.bbm)
+	}
+}
+
+func TestMultipleFlagsOneKey(t *testing.T) {
+
+	// Setup: "filename" is --file on one command and --filename on another.
+	b := NewBinder(viper.New())
+	v := b.Viper()
+	get := pflag.NewFlagSet("get", pflag.ContinueOnError)
+	get.String("file", "", "")
+	put := pflag.NewFlagSet("put", pflag.ContinueOnError)
+	put.String("filename", "", "")
+	put.String("out", "", "")
+	b.Bind("filename", get.Lookup("file"))
+	b.Bind("filename", put.Lookup("filename"))
+	b.Bind("filename", put.Lookup("out"))
+
+	if n := len(b.GetBindFlags()); n != 1 {
+		t.Errorf("Wrong number of BindFlags. Got: %d, Expected: 1", n)
+	}
+
+	// Each flag set applies through its own flag.
+	type tc struct {
+		fs      *pflag.FlagSet
+		args    []string
+		e       interface{}
+		eOrigin string
+	}
+	cases := []tc{
+		{get, []string{"--file", "get.txt"}, "get.txt", "--file"},
+		{put, []string{"--filename", "put.txt"}, "put.txt", "--filename"},
+		{put, []string{"--out", "out.txt"}, "out.txt", "--out"},
+		{put, []string{"--filename", "same.txt", "--out", "same.txt"}, "same.txt", "--filename"},
+	}
+	for i, c := range cases {
+		c.fs.VisitAll(func(pf *pflag.Flag) { pf.Value.Set(pf.DefValue); pf.Changed = false })
+		if err := c.fs.Parse(c.args); err != nil {
+			t.Fatalf("Case %d: unexpected parse error: %v", i, err)
+		}
+		if err := b.ApplyFromFlags(c.fs); err != nil {
+			t.Errorf("Case %d: unexpected error from ApplyFromFlags: %v", i, err)
+		}
+		if g := v.Get("filename"); g != c.e {
+			t.Errorf("Case %d: wrong value. Got: %#v, Expected: %#v", i, g, c.e)
+		}
+		x := b.Explain("filename")
+		if o := x.Candidates[x.Winner].Origin; o != c.eOrigin {
+			t.Errorf("Case %d: wrong origin. Got: %q, Expected: %q", i, o, c.eOrigin)
+		}
+	}
+
+	// Different values for the key in one parse are a conflict, and nothing changes.
+	put.VisitAll(func(pf *pflag.Flag) { pf.Value.Set(pf.DefValue); pf.Changed = false })
+	put.Parse([]string{"--filename", "a.txt", "--out", "b.txt"})
+	err := b.ApplyFromFlags(put)
+	var ce *FlagConflictError
+	if !errors.As(err, &ce) {
+		t.Fatalf("Expected a FlagConflictError. Got: %#v", err)
+	}
+	if ce.Key != "filename" || len(ce.Flags) != 2 {
+		t.Errorf("Wrong conflict. Got: %#v", ce)
+	}
+	if g := v.Get("filename"); g != "same.txt" {
+		t.Errorf("Conflict changed the value. Got: %#v, Expected: %#v", g, "same.txt")
+	}
+	if err := b.PushFlags(put); !errors.As(err, &ce) {
+		t.Errorf("Expected a FlagConflictError from PushFlags. Got: %#v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package vconfig
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/spf13/pflag"
+)
+
+/*
+* Codecs for custom pflag.Value types.
+*
+* A custom Value (a log level, a URL, a byte size ...) reports its own
+* Type() name. Register a Codec under that name and values of that
+* type will get to viper as the go value the Codec decodes, rather than
+* as the string the flag prints.
+ */
+
+// Codec converts between the string form of a flag value and the go value kept in viper.
+type Codec interface {
+	// Decode converts a flag string, e.g. from Value.String() or DefValue, into the go value.
+	Decode(s string) (interface{}, error)
+	// Encode converts a go value back into a string the flag Value will accept in Set.
+	Encode(v interface{}) (string, error)
+}
+
+// ValueDecoder may be implemented by a Codec to take the go value directly from
+// the flag's Value rather than parsing its String().
+type ValueDecoder interface {
+	DecodeValue(v pflag.Value) (interface{}, error)
+}
+
+// CodecFuncs makes a Codec from a pair of functions.
+type CodecFuncs struct {
+	DecodeFunc func(s string) (interface{}, error)
+	EncodeFunc func(v interface{}) (string, error)
+}
+
+// Decode calls DecodeFunc.
+func (c CodecFuncs) Decode(s string) (interface{}, error) { return c.DecodeFunc(s) }
+
+// Encode calls EncodeFunc. Without an EncodeFunc the value is formatted with fmt.
+func (c CodecFuncs) Encode(v interface{}) (string, error) {
+	if c.EncodeFunc == nil {
+		return fmt.Sprint(v), nil
+	}
+	return c.EncodeFunc(v)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[string]Codec)
+)
+
+// RegisterCodec registers c for flags whose Value.Type() is typ.
+// A Codec takes precedence over the built in conversion for the type.
+func RegisterCodec(typ string, c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[typ] = c
+}
+
+// UnregisterCodec removes the Codec for typ.
+func UnregisterCodec(typ string) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	delete(codecs, typ)
+}
+
+// codecFor returns the registered Codec for typ.
+func codecFor(typ string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[typ]
+	return c, ok
+}
+
+// toValue converts v, handed to Set for a key bound to a flag of type typ,
+// into the go value kept in viper. Strings are converted as though they
+// came from the command line. Other values are checked with the Codec, if
+// there is one, and otherwise left alone.
+func toValue(v interface{}, typ string) (interface{}, error) {
+	if s, ok := v.(string); ok {
+		return stringValue(s, typ)
+	}
+	if c, ok := codecFor(typ); ok {
+		if _, err := c.Encode(v); err != nil {
+			return nil, &ConvertError{Type: typ, Value: fmt.Sprint(v), Err: err}
+		}
+	}
+	return v, nil
+}
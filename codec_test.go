@@ -0,0 +1,101 @@
+package vconfig
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// A custom flag type for testing codecs.
+type level int
+
+const (
+	infoLevel level = iota
+	warnLevel
+	errorLevel
+)
+
+var levelNames = []string{"info", "warn", "error"}
+
+func parseLevel(s string) (level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(s, n) {
+			return level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown level %q", s)
+}
+
+func (l *level) String() string { return levelNames[*l] }
+func (l *level) Type() string   { return "level" }
+func (l *level) Set(s string) (err error) {
+	*l, err = parseLevel(s)
+	return err
+}
+
+var levelCodec = CodecFuncs{
+	DecodeFunc: func(s string) (interface{}, error) { return parseLevel(s) },
+	EncodeFunc: func(v interface{}) (string, error) {
+		if l, ok := v.(level); ok && int(l) < len(levelNames) {
+			return levelNames[l], nil
+		}
+		return "", fmt.Errorf("not a level: %#v", v)
+	},
+}
+
+func TestCodec(t *testing.T) {
+
+	// Setup
+	RegisterCodec("level", levelCodec)
+	defer UnregisterCodec("level")
+
+	b := NewBinder(viper.New())
+	lv := warnLevel
+	pflags := pflag.NewFlagSet("Codec", pflag.ContinueOnError)
+	pflags.Var(&lv, "level", "log level")
+	b.Bind("level", pflags.Lookup("level"))
+	pflags.Parse([]string{"--level", "error"})
+
+	// Tests
+	if err := b.ApplyFromFlags(pflags); err != nil {
+		t.Errorf("Unexpected error from ApplyFromFlags: %v", err)
+	}
+	if l, ok := b.Viper().Get("level").(level); !ok || l != errorLevel {
+		t.Errorf("Flag value not decoded. Got: %#v, Expected: %#v", b.Viper().Get("level"), errorLevel)
+	}
+
+	// The default comes back decoded too.
+	b.Apply()
+	if l, ok := b.Viper().Get("level").(level); !ok || l != warnLevel {
+		t.Errorf("Default value not decoded. Got: %#v, Expected: %#v", b.Viper().Get("level"), warnLevel)
+	}
+
+	if err := b.Set("level", "INFO"); err != nil {
+		t.Errorf("Unexpected error from Set: %v", err)
+	}
+	if l, ok := b.Viper().Get("level").(level); !ok || l != infoLevel {
+		t.Errorf("Set value not decoded. Got: %#v, Expected: %#v", b.Viper().Get("level"), infoLevel)
+	}
+
+	var ce *ConvertError
+	if err := b.Set("level", "loud"); !errors.As(err, &ce) {
+		t.Errorf("Expected a ConvertError setting a bad level, got: %v", err)
+	}
+	if err := b.Set("level", level(7)); !errors.As(err, &ce) {
+		t.Errorf("Expected a ConvertError setting an unencodable level, got: %v", err)
+	}
+	if l := b.Viper().Get("level"); l != infoLevel {
+		t.Errorf("Failed Set changed the value. Got: %#v, Expected: %#v", l, infoLevel)
+	}
+
+	if err := b.UpdateChangedFlags(); err != nil {
+		t.Errorf("Unexpected error from UpdateChangedFlags: %v", err)
+	}
+	if bf := b.getBindFlagFor("level"); bf.value != errorLevel {
+		t.Errorf("Bind value not decoded. Got: %#v, Expected: %#v", bf.value, errorLevel)
+	}
+}
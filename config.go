@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
 )
 
@@ -18,10 +17,16 @@ var (
 var (
 	ConfigFileName string
 	ConfigFileRoot string
-	HistoryFile    string
+	HistoryFile    string  // Set by InitConfigE, and by Init under WithXDG, if it's empty.
+	EnvPrefix      string  // Environment variables are only read if they start with this and an underscore.
+	XDG            bool    // Use the XDG Base Directory locations, see WithXDG.
+	ConfigSchema   *Schema // Check the config against this, see WithSchema.
 )
 
 // InitConfig reads in config file and ENV variables if set.
+// Problems reading the config file are printed, and the program
+// exits if the home directory can't be found.
+// Use InitConfigE to handle these yourself.
 func InitConfig() {
 
 	if Debug() {
@@ -29,41 +34,57 @@ func InitConfig() {
 		defer pxf()
 	}
 
-	if ConfigFileRoot == "" {
-		ConfigFileRoot = fmt.Sprintf("%s", AppName)
+	err := InitConfigE()
+	if err == nil {
+		return
 	}
 
-	if HistoryFile == "" {
-		HistoryFile = fmt.Sprintf(".%s_history", AppName)
+	if _, ok := err.(*HomeDirError); ok {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("Error loading config file: %s - %v\n", viper.ConfigFileUsed(), err)
+}
+
+// InitConfigE reads in config file and ENV variables if set.
+// It returns a *ConfigNotFoundError, *ConfigParseError, *ConfigPermissionError,
+// *IncludeError or *HomeDirError for the problems it can identify, and *SchemaError
+// if ConfigSchema is set and the config doesn't fit it, leaving the caller to decide
+// what is fatal (e.g. running without a config file is often fine).
+// ENV variables are picked up even if there is an error with the config file.
+// Set EnvPrefix to only read those for the app, e.g. MYAPP_SERVER_PORT for server.port.
+//
+// The config file is found from AppName, ConfigFileRoot and ConfigFileName,
+// in the XDG Base Directory locations if XDG is set, which also puts
+// HistoryFile under $XDG_STATE_HOME.
+// Use Init to configure this with options instead.
+func InitConfigE() error {
+
+	if Debug() {
+		pef()
+		defer pxf()
 	}
 
-	// Find a config file
-	if ConfigFileName != "" {
-		viper.SetConfigFile(ConfigFileName)
-	} else {
-		viper.SetConfigName(ConfigFileRoot)
-
-		// Find home directory.
-		home, err := homedir.Dir()
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-
-		// Search config in home directory with name ".cobra_test" (without extension).
-		viper.AddConfigPath(".")
-		viper.AddConfigPath(home)
+	if ConfigFileRoot == "" {
+		ConfigFileRoot = fmt.Sprintf("%s", AppName)
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
+	opts := []Option{
+		WithAppName(AppName),
+		WithConfigName(ConfigFileRoot),
+		WithConfigFile(ConfigFileName),
+		WithEnvPrefix(EnvPrefix),
+	}
+	if XDG {
+		opts = append(opts, WithXDG())
+	}
+	if ConfigSchema != nil {
+		opts = append(opts, WithSchema(ConfigSchema))
+	}
 
-	// Read in the config file.
-	if err := viper.ReadInConfig(); err == nil {
-		if Debug() {
-			fmt.Println("Using config file:", viper.ConfigFileUsed())
-		}
-	} else {
-		fmt.Printf("Error loading config file: %s - %v\n", viper.ConfigFileUsed(), err)
+	if HistoryFile == "" {
+		HistoryFile = newOptions(opts...).historyFile()
 	}
 
+	return Init(opts...)
 }
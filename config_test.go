@@ -1,8 +1,14 @@
 package vconfig
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/spf13/viper"
 )
 
 func Test_Debug(t *testing.T) {
@@ -32,3 +38,96 @@ func Test_AppName(t *testing.T) {
 
 	}
 }
+
+func TestInitConfigE(t *testing.T) {
+	type tc struct {
+		name, file, contents string
+		unreadable           bool
+		check                func(err error) bool
+		line                 int
+	}
+
+	isNotFound := func(err error) bool { var e *ConfigNotFoundError; return errors.As(err, &e) }
+	isParse := func(err error) bool { var e *ConfigParseError; return errors.As(err, &e) }
+	isPerm := func(err error) bool { var e *ConfigPermissionError; return errors.As(err, &e) }
+
+	cases := []tc{
+		{name: "good", file: "good.yaml", contents: "debug: false\nscreen: dark\n",
+			check: func(err error) bool { return err == nil }},
+		{name: "missing", file: "missing.yaml", check: isNotFound},
+		{name: "bad yaml", file: "bad.yaml", contents: "a: 1\nb: 2\nc: 3: 4\n", check: isParse, line: 3},
+		{name: "bad json", file: "bad.json", contents: "{\n  \"screen\": \"dark\",\n  oops\n}\n",
+			check: isParse, line: 3},
+		{name: "unreadable", file: "unreadable.yaml", contents: "screen: dark\n", unreadable: true, check: isPerm},
+	}
+
+	dir, err := ioutil.TempDir("", "vconfig")
+	if err != nil {
+		t.Fatalf("Can't make a temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { ConfigFileName = "" }()
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.unreadable && os.Geteuid() == 0 {
+				t.Skip("permissions aren't enforced for root")
+			}
+			viper.Reset()
+			ConfigFileName = filepath.Join(dir, c.file)
+			if c.contents != "" {
+				mode := os.FileMode(0644)
+				if c.unreadable {
+					mode = 0000
+				}
+				if err := ioutil.WriteFile(ConfigFileName, []byte(c.contents), mode); err != nil {
+					t.Fatalf("Can't write config file: %v", err)
+				}
+			}
+
+			err := InitConfigE()
+			if !c.check(err) {
+				t.Errorf("Wrong error for config file %q. Got: %#v", c.file, err)
+			}
+			var pe *ConfigParseError
+			if errors.As(err, &pe) && pe.Line != c.line {
+				t.Errorf("Wrong line for parse error. Got: %d, Expected: %d (%v)", pe.Line, c.line, err)
+			}
+		})
+	}
+
+	// Searching rather than naming a file.
+	viper.Reset()
+	ConfigFileName = ""
+	ConfigFileRoot = "no-such-vconfig-app"
+	defer func() { ConfigFileRoot = "" }()
+	var nf *ConfigNotFoundError
+	if err := InitConfigE(); !errors.As(err, &nf) || nf.Name != ConfigFileRoot {
+		t.Errorf("Expected ConfigNotFoundError for %q, got: %#v", ConfigFileRoot, err)
+	}
+}
+
+func TestInitConfigESchema(t *testing.T) {
+	s, err := ParseSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseSchema: %v", err)
+	}
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	defer func(n string, s *Schema) { ConfigFileName, ConfigSchema = n, s }(ConfigFileName, ConfigSchema)
+	defer reset()
+	reset()
+
+	ConfigFileName = writeConfig(t, dir, "config.yaml", "screen: blue\n")
+	ConfigSchema = s
+	ses := schemaErrors(InitConfigE())
+	if len(ses) != 1 || ses[0].Key != "screen" {
+		t.Errorf("Expected a SchemaError for screen, got: %v", ses)
+	}
+
+	reset()
+	ConfigSchema = nil
+	if err := InitConfigE(); err != nil {
+		t.Errorf("Unexpected error without ConfigSchema: %v", err)
+	}
+}
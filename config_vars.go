@@ -4,7 +4,7 @@ package vconfig
 * Keys to look up values in Viper configuration.
  */
 
-// TODO: Formalize the yaml file structure, or at least document it here.
+// The structure of the config file can be checked with a Schema, see schema.go.
 
 // YAML Variables which show up in viper, but managed here.
 
@@ -16,6 +16,15 @@ package vconfig
 		 debug: true
 */
 const (
-	DebugKey   = "debug"   // bool
-	VerboseKey = "verbose" // bool
+	DebugKey    = "debug"    // bool
+	VerboseKey  = "verbose"  // bool
+	ProfileKey  = "profile"  // string, the profile to use, see profile.go
+	ProfilesKey = "profiles" // map of profile name to its settings
+	IncludeKey  = "include"  // file or list of files merged in, see include.go
+)
+
+// The same keys, typed. See key.go.
+var (
+	DebugSetting   = NewBoolKey(DebugKey, "Print debugging information.", false)
+	VerboseSetting = NewBoolKey(VerboseKey, "Print more about what's happening.", false)
 )
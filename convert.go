@@ -0,0 +1,387 @@
+package vconfig
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+/*
+* Conversion of pflag values into the go values we hand to viper.
+*
+* The built in pflag types are all known here by their Value.Type() name.
+* Values are taken from the typed flag Value (or from GetSlice for slice types)
+* while strings, like a flag's DefValue, are parsed the way pflag parses them.
+* Other types can be handled by registering a Codec (see codec.go).
+ */
+
+// ConvertError reports a flag or string value that couldn't be converted
+// to the go type for the flag's type.
+type ConvertError struct {
+	Flag  string // Flag name, if the value came from a flag.
+	Type  string // pflag type name, e.g. "int", "stringSlice".
+	Value string
+	Err   error
+
+	redacted bool
+}
+
+func (e *ConvertError) Error() string {
+	msg := fmt.Sprintf("can't convert %q to %s: %v", e.Value, e.Type, e.Err)
+	if e.redacted {
+		msg = fmt.Sprintf("can't convert %s to %s", Redacted, e.Type)
+	}
+	if e.Flag != "" {
+		return fmt.Sprintf("flag %q: %s", e.Flag, msg)
+	}
+	return msg
+}
+
+// redact hides the value, for a secret key. The parse error may quote
+// the value too, so it's left out of Error, though Unwrap still has it.
+func (e *ConvertError) redact() {
+	e.Value = Redacted
+	e.redacted = true
+}
+
+// Unwrap returns the underlying parse error.
+func (e *ConvertError) Unwrap() error { return e.Err }
+
+// FlagConflictError is returned when flags bound to the same key are
+// given different values on one command line, e.g. --file a --filename b.
+type FlagConflictError struct {
+	Key    string
+	Flags  []string
+	Values []string
+
+	redacted bool
+}
+
+func (e *FlagConflictError) Error() string {
+	fs := make([]string, len(e.Flags))
+	for i, f := range e.Flags {
+		fs[i] = fmt.Sprintf("--%s=%s", f, e.Values[i])
+	}
+	return fmt.Sprintf("conflicting values for %q from flags %s", e.Key, strings.Join(fs, ", "))
+}
+
+func (e *FlagConflictError) add(pf *pflag.Flag) {
+	v := pf.Value.String()
+	if e.redacted {
+		v = Redacted
+	}
+	e.Flags = append(e.Flags, pf.Name)
+	e.Values = append(e.Values, v)
+}
+
+// redact hides the values, and those added later, for a secret key.
+func (e *FlagConflictError) redact() {
+	for i := range e.Values {
+		e.Values[i] = Redacted
+	}
+	e.redacted = true
+}
+
+// converter turns a pflag value of one type into a go value.
+type converter struct {
+	value func(pflag.Value) (interface{}, error) // from the typed flag value.
+	parse func(string) (interface{}, error)      // from a string.
+}
+
+var (
+	ipType     = reflect.TypeOf(net.IP{})
+	ipMaskType = reflect.TypeOf(net.IPMask{})
+	ipNetType  = reflect.TypeOf(net.IPNet{})
+	bytesType  = reflect.TypeOf([]byte{})
+	durType    = reflect.TypeOf(time.Duration(0))
+)
+
+var converters = map[string]converter{
+	"string":  scalar(reflect.TypeOf(""), func(s string) (interface{}, error) { return s, nil }),
+	"bool":    scalar(reflect.TypeOf(false), parseBool),
+	"int":     number(reflect.TypeOf(int(0))),
+	"int8":    number(reflect.TypeOf(int8(0))),
+	"int16":   number(reflect.TypeOf(int16(0))),
+	"int32":   number(reflect.TypeOf(int32(0))),
+	"int64":   number(reflect.TypeOf(int64(0))),
+	"uint":    number(reflect.TypeOf(uint(0))),
+	"uint8":   number(reflect.TypeOf(uint8(0))),
+	"uint16":  number(reflect.TypeOf(uint16(0))),
+	"uint32":  number(reflect.TypeOf(uint32(0))),
+	"uint64":  number(reflect.TypeOf(uint64(0))),
+	"float32": number(reflect.TypeOf(float32(0))),
+	"float64": number(reflect.TypeOf(float64(0))),
+	"count":   number(reflect.TypeOf(int(0))),
+
+	"duration":    scalar(durType, parseDuration),
+	"ip":          scalar(ipType, parseIP),
+	"ipMask":      scalar(ipMaskType, parseIPMask),
+	"ipNet":       scalar(ipNetType, parseIPNet),
+	"bytesHex":    scalar(bytesType, parseHex),
+	"bytesBase64": scalar(bytesType, parseBase64),
+
+	"stringSlice":   slice(reflect.TypeOf(""), func(s string) (interface{}, error) { return s, nil }),
+	"stringArray":   slice(reflect.TypeOf(""), func(s string) (interface{}, error) { return s, nil }),
+	"boolSlice":     slice(reflect.TypeOf(false), parseBool),
+	"intSlice":      slice(reflect.TypeOf(int(0)), numberParser(reflect.TypeOf(int(0)))),
+	"int32Slice":    slice(reflect.TypeOf(int32(0)), numberParser(reflect.TypeOf(int32(0)))),
+	"int64Slice":    slice(reflect.TypeOf(int64(0)), numberParser(reflect.TypeOf(int64(0)))),
+	"uintSlice":     slice(reflect.TypeOf(uint(0)), numberParser(reflect.TypeOf(uint(0)))),
+	"float32Slice":  slice(reflect.TypeOf(float32(0)), numberParser(reflect.TypeOf(float32(0)))),
+	"float64Slice":  slice(reflect.TypeOf(float64(0)), numberParser(reflect.TypeOf(float64(0)))),
+	"durationSlice": slice(durType, parseDuration),
+	"ipSlice":       slice(ipType, parseIP),
+
+	"stringToString": stringMap(reflect.TypeOf(""), func(s string) (interface{}, error) { return s, nil }),
+	"stringToInt":    stringMap(reflect.TypeOf(int(0)), numberParser(reflect.TypeOf(int(0)))),
+	"stringToInt64":  stringMap(reflect.TypeOf(int64(0)), numberParser(reflect.TypeOf(int64(0)))),
+}
+
+func flagValue(f *pflag.Flag) (interface{}, error) {
+	t := f.Value.Type()
+	var v interface{}
+	var err error
+	if cd, ok := codecFor(t); ok {
+		if vd, ok := cd.(ValueDecoder); ok {
+			v, err = vd.DecodeValue(f.Value)
+		} else {
+			v, err = cd.Decode(f.Value.String())
+		}
+	} else if c, ok := converters[t]; ok {
+		v, err = c.value(f.Value)
+	} else {
+		return f.Value.String(), nil
+	}
+	if err != nil {
+		return nil, &ConvertError{Flag: f.Name, Type: t, Value: f.Value.String(), Err: err}
+	}
+	return v, nil
+}
+
+func flagDefValue(f *pflag.Flag) (interface{}, error) {
+	v, err := stringValue(f.DefValue, f.Value.Type())
+	if ce, ok := err.(*ConvertError); ok {
+		ce.Flag = f.Name
+	}
+	return v, err
+}
+
+// stringValue converts v to the go value for the pflag type t.
+// Types without a Codec that we don't know about are left as strings.
+func stringValue(v, t string) (cv interface{}, err error) {
+	if cd, ok := codecFor(t); ok {
+		cv, err = cd.Decode(v)
+	} else if c, ok := converters[t]; ok {
+		cv, err = c.parse(v)
+	} else {
+		return v, nil
+	}
+	if err != nil {
+		return nil, &ConvertError{Type: t, Value: v, Err: err}
+	}
+	return cv, nil
+}
+
+// scalar converts a flag Value whose underlying type is t.
+func scalar(t reflect.Type, parse func(string) (interface{}, error)) converter {
+	return converter{
+		value: func(pv pflag.Value) (interface{}, error) {
+			rv := reflect.Indirect(reflect.ValueOf(pv))
+			if rv.Kind() == t.Kind() && rv.Type().ConvertibleTo(t) {
+				return rv.Convert(t).Interface(), nil
+			}
+			// Not one of pflag's own values, e.g. a wrapped go flag.
+			return parse(pv.String())
+		},
+		parse: parse,
+	}
+}
+
+func number(t reflect.Type) converter {
+	return scalar(t, numberParser(t))
+}
+
+// numberParser parses ints, uints and floats the way pflag does.
+func numberParser(t reflect.Type) func(string) (interface{}, error) {
+	return func(s string) (v interface{}, err error) {
+		s = strings.TrimSpace(s)
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v, err = strconv.ParseInt(s, 0, t.Bits())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v, err = strconv.ParseUint(s, 0, t.Bits())
+		case reflect.Float32, reflect.Float64:
+			v, err = strconv.ParseFloat(s, t.Bits())
+		default:
+			return nil, fmt.Errorf("%s is not a number type", t)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ValueOf(v).Convert(t).Interface(), nil
+	}
+}
+
+// slice converts the pflag slice types, element by element.
+func slice(et reflect.Type, parse func(string) (interface{}, error)) converter {
+	elems := func(ss []string) (interface{}, error) {
+		out := reflect.MakeSlice(reflect.SliceOf(et), 0, len(ss))
+		for _, s := range ss {
+			v, err := parse(s)
+			if err != nil {
+				return nil, err
+			}
+			out = reflect.Append(out, reflect.ValueOf(v))
+		}
+		return out.Interface(), nil
+	}
+	return converter{
+		value: func(pv pflag.Value) (interface{}, error) {
+			if sv, ok := pv.(pflag.SliceValue); ok {
+				return elems(sv.GetSlice())
+			}
+			ss, err := readList(pv.String())
+			if err != nil {
+				return nil, err
+			}
+			return elems(ss)
+		},
+		parse: func(s string) (interface{}, error) {
+			ss, err := readList(s)
+			if err != nil {
+				return nil, err
+			}
+			return elems(ss)
+		},
+	}
+}
+
+// stringMap converts the pflag stringTo* types.
+func stringMap(vt reflect.Type, parse func(string) (interface{}, error)) converter {
+	mt := reflect.MapOf(reflect.TypeOf(""), vt)
+	fromPairs := func(ss []string) (interface{}, error) {
+		out := reflect.MakeMapWithSize(mt, len(ss))
+		for _, pair := range ss {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("%s must be formatted as key=value", pair)
+			}
+			v, err := parse(kv[1])
+			if err != nil {
+				return nil, err
+			}
+			out.SetMapIndex(reflect.ValueOf(kv[0]), reflect.ValueOf(v))
+		}
+		return out.Interface(), nil
+	}
+	parseMap := func(s string) (interface{}, error) {
+		ss, err := readList(s)
+		if err != nil {
+			return nil, err
+		}
+		return fromPairs(ss)
+	}
+	return converter{
+		value: func(pv pflag.Value) (interface{}, error) {
+			// pflag keeps these as a pointer to the map in an unexported field.
+			// We can read it, but not hand it out, so copy it.
+			rv := reflect.Indirect(reflect.ValueOf(pv))
+			if rv.Kind() == reflect.Struct {
+				if mv := rv.FieldByName("value"); mv.Kind() == reflect.Ptr && !mv.IsNil() &&
+					mv.Elem().Kind() == reflect.Map && mv.Elem().Type().Elem().Kind() == vt.Kind() {
+					out := reflect.MakeMapWithSize(mt, mv.Elem().Len())
+					iter := mv.Elem().MapRange()
+					for iter.Next() {
+						var v reflect.Value
+						switch vt.Kind() {
+						case reflect.String:
+							v = reflect.ValueOf(iter.Value().String())
+						default:
+							v = reflect.ValueOf(iter.Value().Int()).Convert(vt)
+						}
+						out.SetMapIndex(reflect.ValueOf(iter.Key().String()), v)
+					}
+					return out.Interface(), nil
+				}
+			}
+			return parseMap(pv.String())
+		},
+		parse: parseMap,
+	}
+}
+
+// readList reads the comma separated list that pflag uses for slices and maps.
+// DefValue (and String()) for these types is wrapped in [].
+func readList(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
+	}
+	if s == "" {
+		return []string{}, nil
+	}
+	return csv.NewReader(strings.NewReader(s)).Read()
+}
+
+func parseBool(s string) (interface{}, error) {
+	return strconv.ParseBool(strings.TrimSpace(s))
+}
+
+func parseDuration(s string) (interface{}, error) {
+	return time.ParseDuration(strings.TrimSpace(s))
+}
+
+// isNil checks for pflag's printing of a nil ip, mask or network.
+func isNil(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s == "<nil>"
+}
+
+func parseIP(s string) (interface{}, error) {
+	if isNil(s) {
+		return net.IP(nil), nil
+	}
+	ip := net.ParseIP(strings.TrimSpace(s))
+	if ip == nil {
+		return nil, fmt.Errorf("failed to parse IP: %q", s)
+	}
+	return ip, nil
+}
+
+func parseIPMask(s string) (interface{}, error) {
+	if isNil(s) {
+		return net.IPMask(nil), nil
+	}
+	m := pflag.ParseIPv4Mask(strings.TrimSpace(s))
+	if m == nil {
+		return nil, fmt.Errorf("failed to parse IP mask: %q", s)
+	}
+	return m, nil
+}
+
+func parseIPNet(s string) (interface{}, error) {
+	if isNil(s) {
+		return net.IPNet{}, nil
+	}
+	_, n, err := net.ParseCIDR(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	return *n, nil
+}
+
+func parseHex(s string) (interface{}, error) {
+	return hex.DecodeString(strings.TrimSpace(s))
+}
+
+func parseBase64(s string) (interface{}, error) {
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+}
@@ -0,0 +1,192 @@
+package vconfig
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestFlagValueTypes(t *testing.T) {
+	type tc struct {
+		name string
+		arg  string      // command line value
+		e    interface{} // expected from the flag value
+		ed   interface{} // expected from the default value
+	}
+
+	_, ipNet, _ := net.ParseCIDR("10.0.0.0/8")
+	_, ipNetDef, _ := net.ParseCIDR("192.168.0.0/16")
+
+	pflags := pflag.NewFlagSet("FlagValueTypes", pflag.PanicOnError)
+	pflags.String("string", "def", "")
+	pflags.Bool("bool", true, "")
+	pflags.Int("int", 1, "")
+	pflags.Int8("int8", 2, "")
+	pflags.Int16("int16", 3, "")
+	pflags.Int32("int32", 4, "")
+	pflags.Int64("int64", 5, "")
+	pflags.Uint("uint", 6, "")
+	pflags.Uint8("uint8", 7, "")
+	pflags.Uint16("uint16", 8, "")
+	pflags.Uint32("uint32", 9, "")
+	pflags.Uint64("uint64", 10, "")
+	pflags.Float32("float32", 1.5, "")
+	pflags.Float64("float64", 2.5, "")
+	pflags.Duration("duration", time.Minute, "")
+	pflags.Count("count", "")
+	pflags.StringSlice("stringSlice", []string{"a", "b"}, "")
+	pflags.StringArray("stringArray", []string{"c"}, "")
+	pflags.IntSlice("intSlice", []int{1, 2}, "")
+	pflags.BoolSlice("boolSlice", []bool{true}, "")
+	pflags.DurationSlice("durationSlice", []time.Duration{time.Second}, "")
+	pflags.StringToString("stringToString", map[string]string{"a": "1"}, "")
+	pflags.StringToInt("stringToInt", map[string]int{"a": 1}, "")
+	pflags.IP("ip", net.ParseIP("127.0.0.1"), "")
+	pflags.IPNet("ipNet", *ipNetDef, "")
+	pflags.IPMask("ipMask", net.IPv4Mask(255, 255, 0, 0), "")
+	pflags.BytesHex("bytesHex", []byte{0x01}, "")
+	pflags.BytesBase64("bytesBase64", []byte("hi"), "")
+
+	cases := []tc{
+		{name: "string", arg: "value", e: "value", ed: "def"},
+		{name: "bool", arg: "false", e: false, ed: true},
+		{name: "int", arg: "8080", e: int(8080), ed: int(1)},
+		{name: "int8", arg: "-8", e: int8(-8), ed: int8(2)},
+		{name: "int16", arg: "16", e: int16(16), ed: int16(3)},
+		{name: "int32", arg: "32", e: int32(32), ed: int32(4)},
+		{name: "int64", arg: "64", e: int64(64), ed: int64(5)},
+		{name: "uint", arg: "1", e: uint(1), ed: uint(6)},
+		{name: "uint8", arg: "8", e: uint8(8), ed: uint8(7)},
+		{name: "uint16", arg: "16", e: uint16(16), ed: uint16(8)},
+		{name: "uint32", arg: "32", e: uint32(32), ed: uint32(9)},
+		{name: "uint64", arg: "64", e: uint64(64), ed: uint64(10)},
+		{name: "float32", arg: "0.25", e: float32(0.25), ed: float32(1.5)},
+		{name: "float64", arg: "0.5", e: float64(0.5), ed: float64(2.5)},
+		{name: "duration", arg: "90s", e: 90 * time.Second, ed: time.Minute},
+		{name: "count", arg: "3", e: int(3), ed: int(0)},
+		{name: "stringSlice", arg: "x,y", e: []string{"x", "y"}, ed: []string{"a", "b"}},
+		{name: "stringArray", arg: "x,y", e: []string{"x,y"}, ed: []string{"c"}},
+		{name: "intSlice", arg: "3,4", e: []int{3, 4}, ed: []int{1, 2}},
+		{name: "boolSlice", arg: "false,true", e: []bool{false, true}, ed: []bool{true}},
+		{name: "durationSlice", arg: "1m,2s", e: []time.Duration{time.Minute, 2 * time.Second},
+			ed: []time.Duration{time.Second}},
+		{name: "stringToString", arg: "b=2,c=3", e: map[string]string{"b": "2", "c": "3"},
+			ed: map[string]string{"a": "1"}},
+		{name: "stringToInt", arg: "b=2", e: map[string]int{"b": 2}, ed: map[string]int{"a": 1}},
+		{name: "ip", arg: "10.1.1.1", e: net.ParseIP("10.1.1.1"), ed: net.ParseIP("127.0.0.1")},
+		{name: "ipNet", arg: "10.0.0.0/8", e: *ipNet, ed: *ipNetDef},
+		{name: "ipMask", arg: "255.255.255.0", e: net.IPv4Mask(255, 255, 255, 0),
+			ed: net.IPv4Mask(255, 255, 0, 0)},
+		{name: "bytesHex", arg: "0A0B", e: []byte{0x0a, 0x0b}, ed: []byte{0x01}},
+		{name: "bytesBase64", arg: "aGV5", e: []byte("hey"), ed: []byte("hi")},
+	}
+
+	var args []string
+	for _, c := range cases {
+		args = append(args, "--"+c.name+"="+c.arg)
+	}
+	pflags.Parse(args)
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pf := pflags.Lookup(c.name)
+			v, err := flagValue(pf)
+			if err != nil {
+				t.Errorf("Unexpected error converting flag value: %v", err)
+			} else if !reflect.DeepEqual(v, c.e) {
+				t.Errorf("Wrong flag value. Got: %#v, Expected: %#v", v, c.e)
+			}
+			d, err := flagDefValue(pf)
+			if err != nil {
+				t.Errorf("Unexpected error converting default value: %v", err)
+			} else if !reflect.DeepEqual(d, c.ed) {
+				t.Errorf("Wrong default value. Got: %#v, Expected: %#v", d, c.ed)
+			}
+		})
+	}
+}
+
+func TestStringValueErrors(t *testing.T) {
+	type tc struct {
+		v, t string
+	}
+	cases := []tc{
+		{v: "ture", t: "bool"},
+		{v: "8080x", t: "int"},
+		{v: "300", t: "uint8"},
+		{v: "-1", t: "uint"},
+		{v: "fast", t: "duration"},
+		{v: "1,x", t: "intSlice"},
+		{v: "a", t: "stringToString"},
+		{v: "300.1.1.1", t: "ip"},
+		{v: "zz", t: "bytesHex"},
+	}
+	for _, c := range cases {
+		v, err := stringValue(c.v, c.t)
+		var ce *ConvertError
+		if !errors.As(err, &ce) {
+			t.Errorf("Expected a ConvertError for %q as %s. Got value: %#v, error: %v", c.v, c.t, v, err)
+		}
+	}
+
+	// Unknown types stay strings.
+	if v, err := stringValue("anything", "custom"); err != nil || v != "anything" {
+		t.Errorf("Unknown type should come back as a string. Got: %#v, %v", v, err)
+	}
+}
+
+func TestErrorListIsAs(t *testing.T) {
+	_, cerr := stringValue("ture", "bool")
+	el := errorList{errors.New("first"), fmt.Errorf("wrapped: %w", cerr), net.UnknownNetworkError("x")}
+
+	// Called directly, as errors.Is and errors.As only look through Unwrap() []error from Go 1.20.
+	var ce *ConvertError
+	if !el.As(&ce) || ce != cerr {
+		t.Errorf("Expected As to find the ConvertError. Got: %#v", ce)
+	}
+	if !el.Is(cerr) || el.Is(errors.New("first")) {
+		t.Errorf("Wrong result from Is.")
+	}
+	var pe *FlagConflictError
+	if el.As(&pe) {
+		t.Errorf("As found an error that isn't there: %#v", pe)
+	}
+	if err := error(el); !errors.Is(err, net.UnknownNetworkError("x")) || !errors.As(err, &ce) {
+		t.Errorf("Expected errors.Is and errors.As to look at each of the errors.")
+	}
+}
+
+func TestApplyTypedFlags(t *testing.T) {
+
+	// Setup
+	b := NewBinder(viper.New())
+	pflags := pflag.NewFlagSet("ApplyTypedFlags", pflag.ContinueOnError)
+	pflags.Int("port", 80, "")
+	pflags.StringSlice("hosts", nil, "")
+	b.Bind("port", pflags.Lookup("port"))
+	b.Bind("hosts", pflags.Lookup("hosts"))
+	pflags.Parse([]string{"--port", "8080", "--hosts", "a,b"})
+
+	// Tests
+	if err := b.ApplyFromFlags(pflags); err != nil {
+		t.Errorf("Unexpected error from ApplyFromFlags: %v", err)
+	}
+	if p, ok := b.Viper().Get("port").(int); !ok || p != 8080 {
+		t.Errorf("Port should be an int in viper. Got: %#v", b.Viper().Get("port"))
+	}
+	if h := b.Viper().GetStringSlice("hosts"); !reflect.DeepEqual(h, []string{"a", "b"}) {
+		t.Errorf("Hosts should be a slice in viper. Got: %#v", b.Viper().Get("hosts"))
+	}
+
+	// Apply should put back the default.
+	b.Apply()
+	if p, ok := b.Viper().Get("port").(int); !ok || p != 80 {
+		t.Errorf("Port should be restored to the int default. Got: %#v", b.Viper().Get("port"))
+	}
+}
@@ -0,0 +1,133 @@
+package vconfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// unifiedDiff returns the changes from a to b, both the contents of file,
+// in the unified diff format. It's empty if there are none.
+func unifiedDiff(file, a, b string) string {
+	return unifiedDiffShown(file, a, b, a, b)
+}
+
+// unifiedDiffShown is unifiedDiff, showing the lines of showA and showB in place
+// of those of a and b, which they line up with, e.g. with secrets Redacted.
+func unifiedDiffShown(file, a, b, showA, showB string) string {
+	if a == b {
+		return ""
+	}
+	al, bl := diffLines(a), diffLines(b)
+	ops := diffOps(al, bl)
+	sal, sbl := diffLines(showA), diffLines(showB)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", file, file)
+	for i := 0; i < len(ops); {
+		// Find the next change, and the end of its hunk.
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		if end += diffContext; end > len(ops) {
+			end = len(ops)
+		}
+
+		h := ops[start:end]
+		as, bs := h[0].a, h[0].b
+		var an, bn int
+		for _, o := range h {
+			if o.kind != '+' {
+				an++
+			}
+			if o.kind != '-' {
+				bn++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(as, an), hunkRange(bs, bn))
+		for _, o := range h {
+			var line string
+			if o.kind == '+' {
+				line = sbl[o.b]
+			} else {
+				line = sal[o.a]
+			}
+			fmt.Fprintf(&sb, "%c%s\n", o.kind, line)
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+func diffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+	a, b int // Index of the line in each file (where it would go, for the other).
+}
+
+// diffOps finds the lines common to a and b, which is plenty quick for config files.
+func diffOps(a, b []string) (ops []diffOp) {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], i, j})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{'+', b[j], i, j})
+			j++
+		default:
+			ops = append(ops, diffOp{'-', a[i], i, j})
+			i++
+		}
+	}
+	return ops
+}
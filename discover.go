@@ -0,0 +1,157 @@
+package vconfig
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+/*
+* Finding the config file.
+*
+* We do the search rather than leaving it to viper so that we can
+* report on which files were looked for and which one was used.
+*
+* With WithXDG() the search follows the XDG Base Directory spec:
+*
+*   1. the current directory
+*   2. $XDG_CONFIG_HOME/<app>/  (~/.config/<app>/ if not set)
+*   3. the home directory, where we've always looked
+*   4. each of $XDG_CONFIG_DIRS/<app>/ in order (/etc/xdg/<app>/ if not set)
+*   5. /etc/<app>/
+*
+* and the history file goes in $XDG_STATE_HOME/<app>/history
+* (~/.local/state/<app>/history if not set).
+ */
+
+// Discovery reports on the search for a config file.
+type Discovery struct {
+	Candidates []string // Files checked, in order, up to and including the one used.
+	Used       string   // The file used, empty if none was found.
+}
+
+// WithXDG searches for the config file in the XDG Base Directory locations
+// and /etc/<app>/, and puts the history file under $XDG_STATE_HOME.
+// WithSearchPaths takes precedence over this for the search.
+func WithXDG() Option {
+	return func(o *options) { o.xdg = true }
+}
+
+// xdgSearchPaths are the XDG config directories for app, in order.
+func xdgSearchPaths(app, home string) []string {
+	paths := []string{"."}
+	paths = append(paths, filepath.Join(xdgDir("XDG_CONFIG_HOME", filepath.Join(home, ".config")), app))
+	paths = append(paths, home)
+	for _, d := range xdgDirs("XDG_CONFIG_DIRS", "/etc/xdg") {
+		paths = append(paths, filepath.Join(d, app))
+	}
+	return append(paths, filepath.Join("/etc", app))
+}
+
+// xdgHistoryFile is the history file for app under $XDG_STATE_HOME.
+func xdgHistoryFile(app, home string) string {
+	return filepath.Join(xdgDir("XDG_STATE_HOME", filepath.Join(home, ".local", "state")), app, "history")
+}
+
+// xdgDir returns the directory in the environment variable env, or def.
+// The spec says relative paths are to be ignored.
+func xdgDir(env, def string) string {
+	if d := os.Getenv(env); filepath.IsAbs(d) {
+		return d
+	}
+	return def
+}
+
+// xdgDirs returns the colon separated list of directories in env, or def.
+func xdgDirs(env, def string) (dirs []string) {
+	for _, d := range filepath.SplitList(os.Getenv(env)) {
+		if filepath.IsAbs(d) {
+			dirs = append(dirs, d)
+		}
+	}
+	if len(dirs) == 0 {
+		dirs = []string{def}
+	}
+	return dirs
+}
+
+// discover finds the config file the options describe.
+func (o *options) discover() (d Discovery, paths []string, err error) {
+	if o.configFile != "" {
+		d.Candidates = []string{o.configFile}
+		d.Used = o.configFile
+		return d, nil, nil
+	}
+
+	if paths, err = o.searchPaths(); err != nil {
+		return d, paths, err
+	}
+	for _, p := range paths {
+		for _, ext := range viper.SupportedExts {
+			f := filepath.Join(p, o.configName+"."+ext)
+			d.Candidates = append(d.Candidates, f)
+			if isFile(f) {
+				d.Used = f
+				return d, paths, nil
+			}
+		}
+		// Files without an extension, but only if we've been told what they are.
+		if o.configType != "" {
+			f := filepath.Join(p, o.configName)
+			d.Candidates = append(d.Candidates, f)
+			if isFile(f) {
+				d.Used = f
+				return d, paths, nil
+			}
+		}
+	}
+	return d, paths, &ConfigNotFoundError{Name: o.configName, Paths: paths}
+}
+
+// historyFile is where the options put the history file.
+func (o *options) historyFile() string {
+	if o.xdg {
+		if home, err := homedir.Dir(); err == nil {
+			return xdgHistoryFile(o.appName, home)
+		}
+	}
+	return "." + o.appName + "_history"
+}
+
+func isFile(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && !fi.IsDir()
+}
+
+// LastDiscovery reports on the search for the config file done by the last Init.
+func LastDiscovery() Discovery {
+	return std.LastDiscovery()
+}
+
+// LastDiscovery reports on the search for the config file done by the last Init.
+func (b *Binder) LastDiscovery() Discovery {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	d := b.discovery
+	d.Candidates = append([]string(nil), d.Candidates...)
+	return d
+}
+
+// HistoryFilePath returns the history file for the options given to the last Init.
+func HistoryFilePath() string {
+	return std.HistoryFilePath()
+}
+
+// HistoryFilePath returns the history file for the options given to the last Init.
+// InitConfigE sets HistoryFile to this, as Init does under WithXDG, unless it's already set.
+// Under WithXDG() the directory may need to be created before the file is written.
+func (b *Binder) HistoryFilePath() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.opts == nil {
+		return newOptions().historyFile()
+	}
+	return b.opts.historyFile()
+}
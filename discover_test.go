@@ -0,0 +1,104 @@
+package vconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestXDGDiscovery(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	app := "vconfig-xdg-test"
+	cfgHome := filepath.Join(dir, "config")
+	dirOne := filepath.Join(dir, "dirs-one")
+	dirTwo := filepath.Join(dir, "dirs-two")
+	stateHome := filepath.Join(dir, "state")
+
+	for k, v := range map[string]string{
+		"XDG_CONFIG_HOME": cfgHome,
+		"XDG_CONFIG_DIRS": dirOne + string(filepath.ListSeparator) + dirTwo,
+		"XDG_STATE_HOME":  stateHome,
+	} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, v)
+	}
+
+	two := writeConfig(t, dirTwo, app+"/"+app+".yaml", "screen: dirs-two\n")
+
+	// Only in the second of XDG_CONFIG_DIRS.
+	b := NewBinder(viper.New())
+	if err := b.Init(WithAppName(app), WithXDG()); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+	if v := b.Viper().GetString("screen"); v != "dirs-two" {
+		t.Errorf("Wrong config value. Got: %#v, Expected: %#v", v, "dirs-two")
+	}
+	d := b.LastDiscovery()
+	if d.Used != two {
+		t.Errorf("Wrong file used. Got: %#v, Expected: %#v", d.Used, two)
+	}
+	if d.Candidates[len(d.Candidates)-1] != two {
+		t.Errorf("Last candidate should be the one used. Got: %#v", d.Candidates)
+	}
+	// Everything in XDG_CONFIG_HOME and the first XDG_CONFIG_DIRS was checked first.
+	checked := map[string]bool{}
+	for _, c := range d.Candidates {
+		checked[filepath.Dir(c)] = true
+	}
+	for _, p := range []string{filepath.Join(cfgHome, app), filepath.Join(dirOne, app)} {
+		if !checked[p] {
+			t.Errorf("Expected %q to be searched. Candidates: %#v", p, d.Candidates)
+		}
+	}
+	if checked[filepath.Join("/etc", app)] {
+		t.Errorf("Search should have stopped before /etc/%s. Candidates: %#v", app, d.Candidates)
+	}
+
+	// XDG_CONFIG_HOME takes precedence.
+	home := writeConfig(t, cfgHome, app+"/"+app+".json", `{"screen": "config-home"}`)
+	b = NewBinder(viper.New())
+	if err := b.Init(WithAppName(app), WithXDG()); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+	if d := b.LastDiscovery(); d.Used != home {
+		t.Errorf("Wrong file used. Got: %#v, Expected: %#v", d.Used, home)
+	}
+
+	// History goes in the state directory.
+	eh := filepath.Join(stateHome, app, "history")
+	if h := b.HistoryFilePath(); h != eh {
+		t.Errorf("Wrong history file. Got: %#v, Expected: %#v", h, eh)
+	}
+	b = NewBinder(viper.New())
+	b.Init(WithAppName(app))
+	if h := b.HistoryFilePath(); h != "."+app+"_history" {
+		t.Errorf("Wrong history file without XDG. Got: %#v", h)
+	}
+
+	// And HistoryFile, if it isn't set, for InitConfigE with XDG and Init with WithXDG.
+	defer func(a, r, h string, x bool) {
+		AppName, ConfigFileRoot, HistoryFile, XDG = a, r, h, x
+	}(AppName, ConfigFileRoot, HistoryFile, XDG)
+	defer reset()
+	AppName, ConfigFileRoot, HistoryFile, XDG = app, "", "", true
+	if err := InitConfigE(); err != nil {
+		t.Fatalf("Unexpected error from InitConfigE: %v", err)
+	}
+	if HistoryFile != eh {
+		t.Errorf("Wrong HistoryFile from InitConfigE. Got: %#v, Expected: %#v", HistoryFile, eh)
+	}
+	HistoryFile = ""
+	Init(WithAppName(app), WithXDG())
+	if HistoryFile != eh {
+		t.Errorf("Wrong HistoryFile from Init. Got: %#v, Expected: %#v", HistoryFile, eh)
+	}
+	HistoryFile = "history"
+	Init(WithAppName(app), WithXDG())
+	if HistoryFile != "history" {
+		t.Errorf("Init replaced HistoryFile. Got: %#v", HistoryFile)
+	}
+}
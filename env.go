@@ -0,0 +1,211 @@
+package vconfig
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/*
+* Environment variables.
+*
+* Viper reads the environment as strings, and only under the names it
+* makes from the key, so a bound int flag's key comes back from the
+* environment as "9090". Instead we look up the environment for every key
+* we know about: those in the config files, defaults, bindings, the key
+* catalog and any given to BindEnv. Values are converted as they would be
+* from the key's flag (or to the type of its Key or default), and put in
+* viper's override layer, underneath Sets and flags:
+*
+*   default < file < env < Set < flag
+*
+* When a Set or flag value goes away, what's in the environment shows again.
+*
+* The name for a key is the prefix from WithEnvPrefix (if any), an underscore
+* and the key, upper cased with dots and dashes made into underscores:
+* MYAPP_SERVER_PORT for server.port. BindEnv gives a key names of its own,
+* and WithExplicitEnv reads only those keys.
+*
+* The environment is read by Init, Reload and BindEnv, and for a key when
+* it's first bound.
+ */
+
+// BindEnv reads the value for bk from the first of the environment variables names that's set,
+// rather than the name made from the key. With no names the key is read from the
+// environment under the usual name, even with WithExplicitEnv.
+func BindEnv(bk string, names ...string) error {
+	return std.BindEnv(bk, names...)
+}
+
+// BindEnv reads the value for bk from the environment variables names.
+// See the package level BindEnv.
+func (b *Binder) BindEnv(bk string, names ...string) error {
+	if b.Debug() {
+		pef()
+		defer pxf()
+	}
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.refresh()
+	if err := b.bindEnv(bk, names...); err != nil {
+		return err
+	}
+	return b.followProfile()
+}
+
+// bindEnv does the work of BindEnv. b.mu must be held.
+func (b *Binder) bindEnv(bk string, names ...string) error {
+	b.envs[strings.ToLower(bk)] = append([]string{}, names...)
+	return b.loadEnv(bk)
+}
+
+// WithExplicitEnv only reads environment variables for the keys given to BindEnv.
+func WithExplicitEnv() Option {
+	return func(o *options) { o.explicitEnv = true }
+}
+
+// envNames returns the environment variables for key, in the order they're looked at.
+// b.mu must be held.
+func (b *Binder) envNames(key string) []string {
+	key = strings.ToLower(key)
+	names, bound := b.envs[key]
+	o := b.opts
+	switch {
+	case o != nil && !o.env:
+		return nil
+	case len(names) > 0:
+		return names
+	case o == nil && bound:
+		o = newOptions()
+	case o == nil, o.explicitEnv && !bound:
+		return nil
+	}
+	return []string{o.envName(key)}
+}
+
+// lookupEnv returns the environment variable that has the value for key, and the value,
+// converted for the key. The string is returned along with the error if it can't be converted.
+// b.mu must be held.
+func (b *Binder) lookupEnv(key string) (name string, v interface{}, ok bool, err error) {
+	for _, n := range b.envNames(key) {
+		if s, found := os.LookupEnv(n); found {
+			v, err := b.envValue(key, s)
+			if err != nil {
+				return n, s, true, fmt.Errorf("$%s: %w", n, b.redactErr(key, err))
+			}
+			return n, v, true, nil
+		}
+	}
+	return "", nil, false, nil
+}
+
+// envValue converts s from the environment as it would be for key's flag,
+// Key or default. b.mu must be held.
+func (b *Binder) envValue(key, s string) (interface{}, error) {
+	if bf := b.binding(key); bf != nil {
+		return stringValue(s, bf.Flag.Value.Type())
+	}
+	catalogMu.Lock()
+	ki, ok := catalog[key]
+	catalogMu.Unlock()
+	t := ki.Type
+	if !ok {
+		def, ok := b.defaults[key]
+		if !ok || def == nil {
+			return s, nil
+		}
+		t = reflect.TypeOf(def)
+	}
+	fv, err := fieldValue(s, t)
+	if err != nil {
+		return nil, &ConvertError{Type: t.String(), Value: s, Err: err}
+	}
+	return fv.Interface(), nil
+}
+
+// binding returns the binding for key, ignoring case as viper does.
+// b.mu must be held.
+func (b *Binder) binding(key string) *BindFlag {
+	if bf, ok := b.bbm[key]; ok {
+		return bf
+	}
+	for bk, bf := range b.bbm {
+		if strings.EqualFold(bk, key) {
+			return bf
+		}
+	}
+	return nil
+}
+
+// envKeys returns the keys to look for in the environment. b.mu must be held.
+func (b *Binder) envKeys() []string {
+	keys := make(map[string]bool)
+	for k := range b.envs {
+		keys[k] = true
+	}
+	for k := range b.envValues {
+		keys[k] = true
+	}
+	if b.opts != nil && !b.opts.explicitEnv {
+		for _, k := range b.Viper().AllKeys() {
+			keys[k] = true
+		}
+		for k := range b.defaults {
+			keys[k] = true
+		}
+		for k := range b.bbm {
+			keys[strings.ToLower(k)] = true
+		}
+		for _, ki := range Catalog() {
+			keys[strings.ToLower(ki.Name)] = true
+		}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// loadEnv reads the environment for keys, or for all the keys we know about if none are given,
+// giving viper the values that changed. Values that can't be converted are
+// used as strings and reported in the error. b.mu must be held.
+func (b *Binder) loadEnv(keys ...string) error {
+	if len(keys) == 0 {
+		keys = b.envKeys()
+	}
+	var errs errorList
+	v := b.Viper()
+	for _, k := range keys {
+		k = strings.ToLower(k)
+		name, ev, ok, err := b.lookupEnv(k)
+		errs = errs.add(err)
+		old, had := b.envValues[k]
+		if ok == had && reflect.DeepEqual(old, ev) {
+			continue
+		}
+		if ok {
+			if b.Debug() {
+				fmt.Printf("Environment value for %#v from $%s: %#v\n", k, name, b.redact(k, ev))
+			}
+			b.envValues[k] = ev
+		} else {
+			delete(b.envValues, k)
+		}
+		if _, over := b.overrides[k]; over {
+			continue // A Set or flag is on top.
+		}
+		before := v.Get(k)
+		v.Set(k, ev)
+		src := SourceEnv
+		if !ok {
+			src = b.sourceOf(k)
+		}
+		b.changed(k, before, v.Get(k), src)
+	}
+	return errs.err()
+}
@@ -0,0 +1,138 @@
+package vconfig
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func setEnv(t *testing.T, env map[string]string) func() {
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := writeConfig(t, dir, "app.yaml", "screen: file\nserver:\n  port: 80\n")
+	defer setEnv(t, map[string]string{
+		"ENVTEST_SERVER_PORT":  "9090",
+		"ENVTEST_SCREEN":       "env",
+		"ENVTEST_TEST_TIMEOUT": "1m",
+		"API_TOKEN":            "secret",
+	})()
+
+	b := NewBinder(viper.New())
+	v := b.Viper()
+	pflags := pflag.NewFlagSet("Env", pflag.ContinueOnError)
+	pflags.Int("port", 0, "")
+	pflags.String("screen", "", "")
+	b.Bind("server.port", pflags.Lookup("port"))
+	b.Bind("screen", pflags.Lookup("screen"))
+	if err := b.BindEnv("token", "TOKEN", "API_TOKEN"); err != nil {
+		t.Fatalf("Unexpected error from BindEnv: %v", err)
+	}
+	if err := b.Init(WithConfigFile(file), WithEnvPrefix("envtest")); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+
+	// Typed, and over the file.
+	type tc struct {
+		key     string
+		e       interface{}
+		eOrigin string
+	}
+	cases := []tc{
+		{"server.port", 9090, "$ENVTEST_SERVER_PORT"},
+		{"test.timeout", time.Minute, "$ENVTEST_TEST_TIMEOUT"},
+		{"screen", "env", "$ENVTEST_SCREEN"},
+		{"token", "secret", "$API_TOKEN"},
+	}
+	for _, c := range cases {
+		if g := v.Get(c.key); g != c.e {
+			t.Errorf("Wrong value for %q. Got: %#v, Expected: %#v", c.key, g, c.e)
+		}
+		x := b.Explain(c.key)
+		if w := x.Candidates[x.Winner]; w.Source != SourceEnv || w.Origin != c.eOrigin {
+			t.Errorf("Wrong winner for %q. Got: %#v, Expected: %s", c.key, w, c.eOrigin)
+		}
+	}
+
+	// Set goes over it, and it's back when the Set is undone.
+	b.Set("server.port", 7070)
+	b.Apply()
+	if g := v.Get("server.port"); g != 7070 {
+		t.Errorf("Wrong value after Set. Got: %#v, Expected: %#v", g, 7070)
+	}
+	if _, err := b.Undo(); err != nil {
+		t.Fatalf("Unexpected error from Undo: %v", err)
+	}
+	b.Apply()
+	if g := v.Get("server.port"); g != 9090 {
+		t.Errorf("Wrong value after Undo. Got: %#v, Expected: %#v", g, 9090)
+	}
+
+	// Flags go over it too, and it's back when they're gone.
+	pflags.Parse([]string{"--screen", "flag"})
+	b.ApplyFromFlags(pflags)
+	if g := v.Get("screen"); g != "flag" {
+		t.Errorf("Wrong value after flags. Got: %#v, Expected: %#v", g, "flag")
+	}
+	b.ApplyFromFlags(pflag.NewFlagSet("Empty", pflag.ContinueOnError))
+	b.Apply()
+	if g := v.Get("screen"); g != "env" {
+		t.Errorf("Wrong value after flags are cleared. Got: %#v, Expected: %#v", g, "env")
+	}
+
+	// Reload picks up changes to the environment.
+	os.Setenv("ENVTEST_SERVER_PORT", "not a port")
+	os.Unsetenv("ENVTEST_SCREEN")
+	if err := b.Reload(); err == nil {
+		t.Errorf("Expected an error for a value that can't be converted")
+	}
+	if g := v.Get("server.port"); g != "not a port" {
+		t.Errorf("Wrong value after Reload. Got: %#v, Expected: %#v", g, "not a port")
+	}
+	if g := v.Get("screen"); g != "file" {
+		t.Errorf("Wrong value after Reload. Got: %#v, Expected: %#v", g, "file")
+	}
+}
+
+func TestExplicitEnv(t *testing.T) {
+	defer setEnv(t, map[string]string{"DEBUG": "true", "SCREEN": "env", "APP_SCREEN": "app"})()
+
+	b := NewBinder(viper.New())
+	if err := b.Init(WithConfigFile("nothere.yaml"), WithExplicitEnv()); err == nil {
+		t.Errorf("Expected an error for a missing config file")
+	}
+	if b.Debug() || b.Viper().Get("screen") != nil {
+		t.Errorf("Read the environment for keys that weren't bound. Got: %t %#v", b.Debug(), b.Viper().Get("screen"))
+	}
+	b.BindEnv("screen", "APP_SCREEN")
+	b.BindEnv(DebugKey)
+	if g := b.Viper().Get("screen"); g != "app" {
+		t.Errorf("Wrong value for screen. Got: %#v, Expected: %#v", g, "app")
+	}
+	if !b.Debug() {
+		t.Errorf("Debug should be read from $DEBUG once bound")
+	}
+
+	// Unbinding a flag with restore goes back to the environment.
+	pflags := pflag.NewFlagSet("ExplicitEnv", pflag.ContinueOnError)
+	pflags.String("screen", "", "")
+	b.Bind("screen", pflags.Lookup("screen"))
+	b.Set("screen", "set")
+	b.Unbind("screen", true)
+	if g, e := b.Viper().Get("screen"), b.Explain("screen"); g != "app" || e.Candidates[e.Winner].Origin != "$APP_SCREEN" {
+		t.Errorf("Wrong value after Unbind. Got: %#v, Expected: %#v from $APP_SCREEN. Explained: %#v", g, "app", e)
+	}
+}
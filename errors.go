@@ -0,0 +1,142 @@
+package vconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+/*
+* Errors returned when reading the config file.
+*
+* These let a caller decide what is fatal, e.g. to carry on
+* without a config file, but stop on one that doesn't parse.
+ */
+
+// ConfigNotFoundError is returned when there is no config file.
+type ConfigNotFoundError struct {
+	File  string   // Set when a specific file was asked for.
+	Name  string   // Set when searching, the file name without an extension.
+	Paths []string // Where we looked when searching.
+	Err   error
+}
+
+func (e *ConfigNotFoundError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("config file %q not found", e.File)
+	}
+	return fmt.Sprintf("config file %q not found in %q", e.Name, e.Paths)
+}
+
+// Unwrap returns the underlying error.
+func (e *ConfigNotFoundError) Unwrap() error { return e.Err }
+
+// ConfigParseError is returned when the config file can't be parsed.
+// Line is 0 when the parser doesn't tell us where the problem is.
+type ConfigParseError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *ConfigParseError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("can't parse config file %s:%d: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("can't parse config file %s: %v", e.File, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ConfigParseError) Unwrap() error { return e.Err }
+
+// ConfigPermissionError is returned when the config file can't be read
+// because of its permissions.
+type ConfigPermissionError struct {
+	File string
+	Err  error
+}
+
+func (e *ConfigPermissionError) Error() string {
+	return fmt.Sprintf("permission denied reading config file %q", e.File)
+}
+
+// Unwrap returns the underlying error, so errors.Is(err, os.ErrPermission) works.
+func (e *ConfigPermissionError) Unwrap() error { return e.Err }
+
+// HomeDirError is returned when the user's home directory can't be found.
+type HomeDirError struct {
+	Err error
+}
+
+func (e *HomeDirError) Error() string {
+	return fmt.Sprintf("can't find home directory: %v", e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *HomeDirError) Unwrap() error { return e.Err }
+
+// readInConfig reads the config file into v returning one of the errors above
+// where the viper error lets us tell what happened.
+func readInConfig(v *viper.Viper, name string, paths []string) error {
+	err := v.ReadInConfig()
+	if err == nil {
+		return nil
+	}
+	file := v.ConfigFileUsed()
+
+	switch e := err.(type) {
+	case viper.ConfigFileNotFoundError:
+		return &ConfigNotFoundError{Name: name, Paths: paths, Err: err}
+	case viper.ConfigParseError:
+		return &ConfigParseError{File: file, Line: errorLine(file, e), Err: err}
+	}
+	if os.IsNotExist(err) {
+		return &ConfigNotFoundError{File: file, Err: err}
+	}
+	if os.IsPermission(err) {
+		return &ConfigPermissionError{File: file, Err: err}
+	}
+	return err
+}
+
+var (
+	yamlLine = regexp.MustCompile(`line (\d+)`)
+	tomlLine = regexp.MustCompile(`^\((\d+), \d+\)`)
+)
+
+// errorLine digs the line number of a parse error out of the error message,
+// or for JSON, which only gives an offset, out of the file.
+func errorLine(file string, err error) int {
+	msg := err.Error()
+	msg = strings.TrimPrefix(msg, "While parsing config: ")
+	switch strings.ToLower(strings.TrimPrefix(filepath.Ext(file), ".")) {
+	case "yaml", "yml":
+		if m := yamlLine.FindStringSubmatch(msg); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			return n
+		}
+	case "toml":
+		if m := tomlLine.FindStringSubmatch(msg); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			return n
+		}
+	case "json":
+		data, rerr := ioutil.ReadFile(file)
+		if rerr != nil {
+			return 0
+		}
+		var x interface{}
+		if se, ok := json.Unmarshal(data, &x).(*json.SyntaxError); ok {
+			return bytes.Count(data[:se.Offset], []byte("\n")) + 1
+		}
+	}
+	return 0
+}
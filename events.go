@@ -0,0 +1,235 @@
+package vconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/*
+* Change notification.
+*
+* Every change to a value made through a Binder (Set, Toggle, Apply,
+* ApplyFromFlags, Init, Reload and Watch) is delivered to the
+* subscribers for the key, and to those for all keys, as a ChangeEvent.
+*
+* Events are delivered one at a time, in the order the changes were made,
+* and in the order the subscriptions were made. They're delivered without
+* the Binder being locked, so a callback may call Set (or anything else).
+* The events from that are delivered after the current one is done
+* with, rather than in the middle of it.
+ */
+
+// Source says where a value came from. Sources are listed in order of precedence.
+type Source int
+
+// Sources of values, lowest precedence first.
+const (
+	SourceDefault Source = iota
+	SourceFile
+	SourceEnv
+	SourceSet
+	SourceFlag
+)
+
+var sourceNames = []string{"default", "file", "env", "set", "flag"}
+
+func (s Source) String() string {
+	if int(s) < len(sourceNames) && s >= 0 {
+		return sourceNames[s]
+	}
+	return "unknown"
+}
+
+// MarshalText writes the Source by name.
+func (s Source) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText reads a Source written by MarshalText.
+func (s *Source) UnmarshalText(text []byte) error {
+	for i, n := range sourceNames {
+		if n == string(text) {
+			*s = Source(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown source: %q", text)
+}
+
+// ChangeEvent describes a change to the value of Key.
+type ChangeEvent struct {
+	Key    string
+	Old    interface{}
+	New    interface{}
+	Source Source
+}
+
+// AllKeys subscribes to changes for every key.
+const AllKeys = "*"
+
+type subscription struct {
+	id  int
+	key string // or AllKeys
+	fn  func(ChangeEvent)
+}
+
+// Subscribe calls fn with each change to the value of key.
+// Use AllKeys for changes to any key.
+// Calling the returned function cancels the subscription.
+func Subscribe(key string, fn func(ChangeEvent)) (cancel func()) {
+	return std.Subscribe(key, fn)
+}
+
+// Subscribe calls fn with each change to the value of key.
+// See the package level Subscribe.
+func (b *Binder) Subscribe(key string, fn func(ChangeEvent)) (cancel func()) {
+	if key != AllKeys {
+		key = strings.ToLower(key)
+	}
+	b.evMu.Lock()
+	defer b.evMu.Unlock()
+	b.nextSub++
+	id := b.nextSub
+	b.subs = append(b.subs, subscription{id: id, key: key, fn: fn})
+	return func() {
+		b.evMu.Lock()
+		defer b.evMu.Unlock()
+		for i, s := range b.subs {
+			if s.id == id {
+				b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// OnChange calls fn with the old and new values each time the value of key changes.
+// Calling the returned function cancels it.
+func OnChange(key string, fn func(old, new interface{})) (cancel func()) {
+	return std.OnChange(key, fn)
+}
+
+// OnChange calls fn with the old and new values each time the value of key changes.
+// Calling the returned function cancels it.
+func (b *Binder) OnChange(key string, fn func(old, new interface{})) (cancel func()) {
+	return b.Subscribe(key, func(ev ChangeEvent) { fn(ev.Old, ev.New) })
+}
+
+// OnAnyChange calls fn each time the value of any key changes.
+// Calling the returned function cancels it.
+func OnAnyChange(fn func(ChangeEvent)) (cancel func()) {
+	return std.Subscribe(AllKeys, fn)
+}
+
+// OnAnyChange calls fn each time the value of any key changes.
+// Calling the returned function cancels it.
+func (b *Binder) OnAnyChange(fn func(ChangeEvent)) (cancel func()) {
+	return b.Subscribe(AllKeys, fn)
+}
+
+// setViper sets key in viper to val, queuing a ChangeEvent if that
+// changes its value. b.mu must be held.
+func (b *Binder) setViper(key string, val interface{}, src Source) {
+	v := b.Viper()
+	old := v.Get(key)
+	b.setOverride(key, val)
+	if val == nil {
+		// Something lower down shows through.
+		src = b.sourceOf(key)
+	}
+	b.changed(key, old, v.Get(key), src)
+}
+
+// setOverride puts val in viper's override layer for key, or with a nil val takes
+// key out of it, so what's lower down shows through. b.mu must be held.
+func (b *Binder) setOverride(key string, val interface{}) {
+	v := b.Viper()
+	v.Set(key, val)
+	if val == nil {
+		delete(b.overrides, strings.ToLower(key))
+		// The environment is underneath, see env.go.
+		if ev, ok := b.envValues[strings.ToLower(key)]; ok {
+			v.Set(key, ev)
+		}
+	} else {
+		b.overrides[strings.ToLower(key)] = val
+	}
+}
+
+// changed queues a ChangeEvent if old and new differ.
+func (b *Binder) changed(key string, old, new interface{}, src Source) {
+	if reflect.DeepEqual(old, new) {
+		return
+	}
+	b.evMu.Lock()
+	b.queue = append(b.queue, ChangeEvent{Key: strings.ToLower(key), Old: old, New: new, Source: src})
+	b.evMu.Unlock()
+}
+
+// changedSettings queues events for the differences between two sets of settings,
+// as returned by b.settings(), and returns the keys that changed.
+// b.mu must be held.
+func (b *Binder) changedSettings(before, after map[string]interface{}) []string {
+	keys := changedKeys(before, after)
+	for _, k := range keys {
+		b.changed(k, before[k], after[k], b.sourceOf(k))
+	}
+	return keys
+}
+
+// deliver calls the subscribers with each queued event. It must be called
+// without b.mu held. If events are already being delivered, on this goroutine
+// or another, it leaves the new ones for that to deliver.
+func (b *Binder) deliver() {
+	b.evMu.Lock()
+	if b.delivering {
+		b.evMu.Unlock()
+		return
+	}
+	b.delivering = true
+	b.evMu.Unlock()
+
+	done := false
+	defer func() {
+		// A subscriber panicked, let the next change deliver what's left.
+		if !done {
+			b.evMu.Lock()
+			b.delivering = false
+			b.evMu.Unlock()
+		}
+	}()
+	for {
+		b.evMu.Lock()
+		if len(b.queue) == 0 {
+			b.delivering = false
+			done = true
+			b.evMu.Unlock()
+			return
+		}
+		ev := b.queue[0]
+		b.queue = b.queue[1:]
+		var fns []func(ChangeEvent)
+		for _, s := range b.subs {
+			if s.key == AllKeys || s.key == ev.Key {
+				fns = append(fns, s.fn)
+			}
+		}
+		b.evMu.Unlock()
+		for _, fn := range fns {
+			fn(ev)
+		}
+	}
+}
+
+// sortedBindKeys returns the bound keys in order, so events come out in a known order.
+// b.mu must be held.
+func (b *Binder) sortedBindKeys() []string {
+	keys := make([]string, 0, len(b.bbm))
+	for k := range b.bbm {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,95 @@
+package vconfig
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestChangeEvents(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := writeConfig(t, dir, "app.yaml", "screen: file\n")
+
+	b := NewBinder(viper.New())
+	var events []ChangeEvent
+	cancel := b.OnAnyChange(func(ev ChangeEvent) { events = append(events, ev) })
+	var debugChanges [][2]interface{}
+	b.OnChange(DebugKey, func(old, new interface{}) { debugChanges = append(debugChanges, [2]interface{}{old, new}) })
+
+	if err := b.Init(WithConfigFile(file), WithoutEnv()); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+
+	pflags := pflag.NewFlagSet("Events", pflag.ContinueOnError)
+	pflags.Bool(DebugKey, false, "")
+	pflags.String("screen", "", "")
+	b.Bind(DebugKey, pflags.Lookup(DebugKey))
+	b.Bind("screen", pflags.Lookup("screen"))
+
+	b.Toggle(DebugKey)
+	b.Set(DebugKey, true) // No change, no event.
+	pflags.Parse([]string{"--screen", "flag"})
+	if err := b.ApplyFromFlags(pflags); err != nil {
+		t.Fatalf("Unexpected error from ApplyFromFlags: %v", err)
+	}
+	b.Apply() // Takes the flag value away again.
+
+	type tc struct {
+		key      string
+		old, new interface{}
+		src      Source
+	}
+	expected := []tc{
+		{key: "screen", old: nil, new: "file", src: SourceFile},
+		{key: DebugKey, old: nil, new: true, src: SourceSet},
+		{key: "screen", old: "file", new: "flag", src: SourceFlag},
+		{key: "screen", old: "flag", new: "file", src: SourceFile},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Wrong number of events. Got: %#v, Expected: %#v", events, expected)
+	}
+	for i, e := range expected {
+		ev := events[i]
+		if ev.Key != e.key || !reflect.DeepEqual(ev.Old, e.old) || !reflect.DeepEqual(ev.New, e.new) || ev.Source != e.src {
+			t.Errorf("Wrong event %d. Got: %#v, Expected: %#v", i, ev, e)
+		}
+	}
+	if len(debugChanges) != 1 || debugChanges[0] != [2]interface{}{nil, true} {
+		t.Errorf("Wrong debug changes. Got: %#v", debugChanges)
+	}
+
+	// Cancelled subscriptions hear nothing more.
+	cancel()
+	n := len(events)
+	b.Set("screen", "set")
+	if len(events) != n {
+		t.Errorf("Cancelled subscription got events: %#v", events[n:])
+	}
+}
+
+func TestChangeEventsFromCallback(t *testing.T) {
+	b := NewBinder(viper.New())
+
+	// A callback that calls Set doesn't deadlock, and the events
+	// from its Set come after the one it's handling, for every subscriber.
+	var order []string
+	b.OnChange("a", func(old, new interface{}) {
+		order = append(order, "first a")
+		b.Set("b", new)
+	})
+	b.OnChange("a", func(old, new interface{}) { order = append(order, "second a") })
+	b.OnChange("b", func(old, new interface{}) { order = append(order, "b") })
+
+	b.Set("a", 1)
+	e := []string{"first a", "second a", "b"}
+	if !reflect.DeepEqual(order, e) {
+		t.Errorf("Wrong delivery order. Got: %#v, Expected: %#v", order, e)
+	}
+	if v := b.Viper().GetInt("b"); v != 1 {
+		t.Errorf("Set from callback wasn't made. Got: %#v, Expected: %#v", v, 1)
+	}
+}
@@ -0,0 +1,184 @@
+package vconfig
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/juju/ansiterm"
+)
+
+/*
+* Precedence.
+*
+* Viper keeps flags and Sets in the same override layer, so it can't
+* tell us where a value came from. The Binder keeps track instead, and
+* the value for a key comes from the first of these that has one:
+*
+*   flag:    a flag changed on this command (PushFlags), or on the
+*            command line (ApplyFromFlags)
+*   set:     Set, or a flag value captured by UpdateChangedFlags
+*   env:     the environment variable for the key
+*   file:    the config file(s), the last layer first
+*   default: SetDefault, or the default of a flag applied with ApplyFromFlags
+*
+* Explain reports on each of them for a key.
+ */
+
+// Candidate is a value for a key from one place in the precedence order.
+type Candidate struct {
+	Source Source
+	Origin string // e.g. the file, environment variable or flag.
+	Value  interface{}
+}
+
+// Explanation says where the value of a key comes from.
+type Explanation struct {
+	Key        string
+	Value      interface{} // What viper returns for Key.
+	Winner     int         // Index of the Candidate Value comes from, -1 if none.
+	Candidates []Candidate // Every value for Key, lowest precedence first.
+	Secret     bool        // Key is secret, so String doesn't show the values.
+}
+
+// Source returns where the value comes from, SourceDefault if nothing provides one.
+func (e Explanation) Source() Source {
+	if e.Winner < 0 {
+		return SourceDefault
+	}
+	return e.Candidates[e.Winner].Source
+}
+
+// String returns a table of the candidates with the winner marked.
+func (e Explanation) String() string {
+	show := func(v interface{}) interface{} {
+		if e.Secret && v != nil {
+			return Redacted
+		}
+		return v
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s = %#v\n", e.Key, show(e.Value))
+	w := ansiterm.NewTabWriter(&sb, 4, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "\tSource\tOrigin\tValue\n")
+	for i := len(e.Candidates) - 1; i >= 0; i-- {
+		c := e.Candidates[i]
+		mark := ""
+		if i == e.Winner {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%#v\n", mark, c.Source, c.Origin, show(c.Value))
+	}
+	w.Flush()
+	return sb.String()
+}
+
+// Explain reports on every value there is for key, and which is used.
+func Explain(key string) Explanation {
+	return std.Explain(key)
+}
+
+// Explain reports on every value there is for key, and which is used.
+// Changes made to viper other than through the Binder aren't seen,
+// in which case Value might not be the Winner's.
+func (b *Binder) Explain(key string) Explanation {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.explain(key)
+}
+
+// explain does the work of Explain. b.mu must be held.
+func (b *Binder) explain(key string) Explanation {
+	bf := b.bbm[key]
+	key = strings.ToLower(key)
+	if bf == nil {
+		bf = b.bbm[key]
+	}
+	e := Explanation{Key: key, Value: b.Viper().Get(key), Winner: -1, Secret: b.isSecret(key)}
+	add := func(c Candidate, wins bool) {
+		e.Candidates = append(e.Candidates, c)
+		if wins {
+			e.Winner = len(e.Candidates) - 1
+		}
+	}
+
+	if v, ok := b.defaults[key]; ok {
+		add(Candidate{Source: SourceDefault, Origin: "default", Value: v}, true)
+	}
+
+	used, inFile := b.layerFor(key)
+	for _, l := range b.layers {
+		if v, ok := lookupSetting(l.settings, key); ok {
+			add(Candidate{Source: SourceFile, Origin: l.Name + ": " + l.File, Value: v}, inFile && l.Layer == used)
+		}
+	}
+
+	if name, v, ok, _ := b.lookupEnv(key); ok {
+		add(Candidate{Source: SourceEnv, Origin: "$" + name, Value: v}, true)
+	}
+
+	if bf != nil && bf.value != nil {
+		origin := "set"
+		if bf.source == SourceFlag {
+			origin = "captured from --" + bf.valueFrom
+		}
+		add(Candidate{Source: SourceSet, Origin: origin, Value: bf.value}, true)
+	} else if v, ok := b.sets[key]; ok {
+		add(Candidate{Source: SourceSet, Origin: "set", Value: v}, true)
+	}
+
+	if bf != nil && bf.flagged {
+		add(Candidate{Source: SourceFlag, Origin: "--" + bf.appliedFrom, Value: bf.applied}, true)
+	}
+
+	if f, fv := b.framed(key); f != nil {
+		add(Candidate{Source: SourceFlag, Origin: "--" + fv.flag + " (this command)", Value: fv.value}, true)
+	}
+	return e
+}
+
+// sourceOf returns where the value for key comes from. b.mu must be held.
+func (b *Binder) sourceOf(key string) Source {
+	return b.explain(key).Source()
+}
+
+// SetDefault sets the value used for key when there's no other.
+func SetDefault(key string, value interface{}) {
+	std.SetDefault(key, value)
+}
+
+// SetDefault sets the value used for key when there's no other.
+func (b *Binder) SetDefault(key string, value interface{}) {
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.refresh()
+	b.setDefault(key, value)
+}
+
+// setDefault gives viper a default for key. b.mu must be held.
+func (b *Binder) setDefault(key string, value interface{}) {
+	v := b.Viper()
+	old := v.Get(key)
+	v.SetDefault(key, value)
+	b.defaults[strings.ToLower(key)] = value
+	b.changed(key, old, v.Get(key), b.sourceOf(key))
+}
+
+// lookupSetting finds the value for a dotted key in nested settings.
+func lookupSetting(settings map[string]interface{}, key string) (interface{}, bool) {
+	parts := strings.Split(key, ".")
+	m := settings
+	for i, p := range parts {
+		v, ok := m[p]
+		if !ok {
+			return nil, false
+		}
+		if i == len(parts)-1 {
+			return v, true
+		}
+		if m, ok = v.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
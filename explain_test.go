@@ -0,0 +1,98 @@
+package vconfig
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestExplain(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	sys := writeConfig(t, dir, "etc/app.yaml", "verbose: false\nscreen: system\n")
+	proj := writeConfig(t, dir, "project/app.yaml", "verbose: true\n")
+
+	os.Setenv("EXPLAINTEST_SCREEN", "env")
+	defer os.Unsetenv("EXPLAINTEST_SCREEN")
+
+	b := NewBinder(viper.New())
+	b.SetDefault("connection", "none")
+	err := b.Init(WithLayers(Layer{Name: SystemLayer, File: sys}, Layer{Name: ProjectLayer, File: proj}),
+		WithEnvPrefix("explaintest"))
+	if err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+
+	pflags := pflag.NewFlagSet("Explain", pflag.ContinueOnError)
+	pflags.Bool(VerboseKey, false, "")
+	pflags.String("screen", "", "")
+	pflags.String("filename", "default-file", "")
+	b.Bind(VerboseKey, pflags.Lookup(VerboseKey))
+	b.Bind("screen", pflags.Lookup("screen"))
+	b.Bind("filename", pflags.Lookup("filename"))
+	b.Set("screen", "set")
+	b.Set("unbound", 3)
+	pflags.Parse([]string{"--verbose=false", "--filename", "flag-file"})
+	if err := b.ApplyFromFlags(pflags); err != nil {
+		t.Fatalf("Unexpected error from ApplyFromFlags: %v", err)
+	}
+
+	type tc struct {
+		key     string
+		value   interface{}
+		source  Source
+		sources []Source
+	}
+	cases := []tc{
+		{key: "connection", value: "none", source: SourceDefault, sources: []Source{SourceDefault}},
+		{key: VerboseKey, value: false, source: SourceFlag, sources: []Source{SourceFile, SourceFile, SourceFlag}},
+		{key: "screen", value: "set", source: SourceSet, sources: []Source{SourceFile, SourceEnv, SourceSet}},
+		{key: "filename", value: "flag-file", source: SourceFlag, sources: []Source{SourceDefault, SourceFlag}},
+		{key: "unbound", value: 3, source: SourceSet, sources: []Source{SourceSet}},
+		{key: "nothing", value: nil, source: SourceDefault, sources: nil},
+	}
+	for _, c := range cases {
+		e := b.Explain(c.key)
+		if e.Value != c.value || e.Source() != c.source {
+			t.Errorf("Wrong value or source for %q. Got: %#v from %s, Expected: %#v from %s", c.key, e.Value, e.Source(), c.value, c.source)
+		}
+		var sources []Source
+		for _, cd := range e.Candidates {
+			sources = append(sources, cd.Source)
+		}
+		if len(sources) != len(c.sources) {
+			t.Errorf("Wrong candidates for %q. Got: %v, Expected: %v", c.key, sources, c.sources)
+			continue
+		}
+		for i := range sources {
+			if sources[i] != c.sources[i] {
+				t.Errorf("Wrong candidates for %q. Got: %v, Expected: %v", c.key, sources, c.sources)
+				break
+			}
+		}
+		if e.Winner >= 0 && e.Candidates[e.Winner].Value != e.Value {
+			t.Errorf("Winner for %q isn't the value. Got: %#v, Expected: %#v", c.key, e.Candidates[e.Winner].Value, e.Value)
+		}
+	}
+
+	// The project file wins among the files, and it shows up in the table.
+	e := b.Explain(VerboseKey)
+	if e.Candidates[1].Origin != ProjectLayer+": "+proj {
+		t.Errorf("Wrong origin. Got: %#v, Expected: %#v", e.Candidates[1].Origin, ProjectLayer+": "+proj)
+	}
+	if s := e.String(); !strings.Contains(s, "--verbose") || !strings.Contains(s, proj) {
+		t.Errorf("Explanation is missing candidates: %s", s)
+	}
+
+	// Without the flag on the command line, the file shows through again.
+	pflags = pflag.NewFlagSet("Explain", pflag.ContinueOnError)
+	pflags.Bool(VerboseKey, false, "")
+	b.Bind(VerboseKey, pflags.Lookup(VerboseKey))
+	b.ApplyFromFlags(pflags)
+	if e := b.Explain(VerboseKey); e.Value != true || e.Source() != SourceFile {
+		t.Errorf("Wrong value after flag removed. Got: %#v from %s, Expected: true from file", e.Value, e.Source())
+	}
+}
@@ -0,0 +1,166 @@
+package vconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+/*
+* Includes.
+*
+* A config file can pull in other files with a top level include, a file
+* or a list of them, which may be globs. Relative names are relative to
+* the directory of the file that includes them:
+*
+*	include:
+*	  - base.yaml
+*	  - conf.d/*.yaml
+*	server:
+*	  port: 8080
+*
+* The files are merged in the order they're listed, the files matching a
+* glob in name order, each over the ones before, and the including file
+* goes over all of them. Included files can include others in the same
+* way. A file that's already been included is skipped, and one that
+* includes itself, directly or not, is an error. A glob that matches
+* nothing is fine, a file that isn't there is not.
+*
+* Each included file is a layer of its own, named IncludeLayer, just
+* before the layer that includes it, so LayerFor and Explain say which
+* file a value came from. Watch watches the included files, and the
+* globs for files that appear.
+ */
+
+// IncludeLayer is the name of the layers for included files.
+const IncludeLayer = "include"
+
+// IncludeError is an include in File that can't be read.
+type IncludeError struct {
+	File    string   // The file with the include.
+	Include string   // The entry in include.
+	Cycle   []string // The files in the cycle, if there is one, starting and ending with the same file.
+	Err     error
+}
+
+func (e *IncludeError) Error() string {
+	if len(e.Cycle) > 0 {
+		return fmt.Sprintf("%s: include %s includes itself: %s", e.File, e.Include, strings.Join(e.Cycle, " -> "))
+	}
+	return fmt.Sprintf("%s: include %s: %v", e.File, e.Include, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *IncludeError) Unwrap() error { return e.Err }
+
+// includeReader reads config files along with those they include.
+type includeReader struct {
+	opts  *options
+	debug bool            // Trace the files included.
+	seen  map[string]bool // Files read, by absolute path.
+	stack []string        // Files being read, to find cycles.
+	globs []string        // Absolute patterns from include, for Watch.
+}
+
+func newIncludeReader(o *options, debug bool) *includeReader {
+	return &includeReader{opts: o, debug: debug, seen: make(map[string]bool)}
+}
+
+// read reads the file for l and the files it includes, returning them in the order to
+// merge them: what's included, then l. paths are reported if l's file can't be found.
+func (r *includeReader) read(l Layer, paths []string) ([]loadedLayer, error) {
+	abs := absPath(l.File)
+	r.seen[abs] = true
+	r.stack = append(r.stack, abs)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	lv := viper.New()
+	lv.SetConfigFile(l.File)
+	// The file found by Init is read as the config type given, included files only if they have no extension.
+	if t := r.opts.configType; t != "" && (len(r.stack) == 1 || filepath.Ext(l.File) == "") {
+		lv.SetConfigType(t)
+	}
+	if err := readInConfig(lv, r.opts.configName, paths); err != nil {
+		return nil, err
+	}
+	settings := lv.AllSettings()
+	incs, err := includes(settings[IncludeKey])
+	if err != nil {
+		return nil, &IncludeError{File: l.File, Include: fmt.Sprint(settings[IncludeKey]), Err: err}
+	}
+	delete(settings, IncludeKey)
+	var keys []string
+	for _, k := range lv.AllKeys() {
+		if k != IncludeKey {
+			keys = append(keys, k)
+		}
+	}
+
+	var found []loadedLayer
+	for _, inc := range incs {
+		files, err := r.match(l.File, inc)
+		if err != nil {
+			return nil, &IncludeError{File: l.File, Include: inc, Err: err}
+		}
+		for _, f := range files {
+			fabs := absPath(f)
+			for i, s := range r.stack {
+				if s == fabs {
+					cycle := append(append([]string{}, r.stack[i:]...), fabs)
+					return nil, &IncludeError{File: l.File, Include: inc, Cycle: cycle}
+				}
+			}
+			if r.seen[fabs] {
+				continue
+			}
+			if r.debug {
+				fmt.Printf("Including %s in %s\n", f, l.File)
+			}
+			ls, err := r.read(Layer{Name: IncludeLayer, File: f}, nil)
+			if err != nil {
+				return nil, err
+			}
+			found = append(found, ls...)
+		}
+	}
+	return append(found, loadedLayer{Layer: l, keys: keys, settings: settings}), nil
+}
+
+// match returns the files for the include inc in the file from.
+func (r *includeReader) match(from, inc string) ([]string, error) {
+	p, err := homedir.Expand(inc)
+	if err != nil {
+		return nil, &HomeDirError{Err: err}
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(filepath.Dir(from), p)
+	}
+	if !isGlob(p) {
+		if _, err := os.Stat(p); err != nil {
+			return nil, err
+		}
+		return []string{p}, nil
+	}
+	r.globs = append(r.globs, absPath(p))
+	return filepath.Glob(p) // Sorted.
+}
+
+// includes returns the entries of an include, a string or a list of them.
+func includes(v interface{}) ([]string, error) {
+	switch inc := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{inc}, nil
+	}
+	return cast.ToStringSliceE(v)
+}
+
+func isGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
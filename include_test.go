@@ -0,0 +1,163 @@
+package vconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestInclude(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := writeConfig(t, dir, "app.yaml", `include:
+  - base.yaml
+  - conf.d/*.yaml
+  - nothing/*.yaml
+screen: app
+`)
+	common := writeConfig(t, dir, "common.yaml", "screen: common\nconnection: common\nfilename: common\nport: 1\n")
+	base := writeConfig(t, dir, "base.yaml", "include: common.yaml\nconnection: base\n")
+	a := writeConfig(t, dir, "conf.d/a.yaml", "include: ../common.yaml\nfilename: a\nserver:\n  host: a\n")
+	writeConfig(t, dir, "conf.d/b.json", `{"server": {"host": "b", "port": 2}}`) // Doesn't match.
+	b2 := writeConfig(t, dir, "conf.d/b.yaml", "server:\n  port: 3\n")
+
+	b := NewBinder(viper.New())
+	if err := b.Init(WithConfigFile(file), WithoutEnv()); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+
+	type tc struct {
+		key  string
+		e    interface{}
+		file string
+	}
+	cases := []tc{
+		{key: "screen", e: "app", file: file},
+		{key: "connection", e: "base", file: base},
+		{key: "filename", e: "a", file: a},
+		{key: "port", e: 1, file: common},
+		{key: "server.host", e: "a", file: a},
+		{key: "server.port", e: 3, file: b2},
+		{key: IncludeKey, e: nil},
+	}
+	for _, c := range cases {
+		if v := b.Viper().Get(c.key); !reflect.DeepEqual(v, c.e) {
+			t.Errorf("Wrong value for %q. Got: %#v, Expected: %#v", c.key, v, c.e)
+		}
+		l, ok := b.LayerFor(c.key)
+		if c.file == "" {
+			if ok {
+				t.Errorf("Expected no layer for %q. Got: %#v", c.key, l)
+			}
+			continue
+		}
+		if l.File != c.file {
+			t.Errorf("Wrong layer for %q. Got: %#v, Expected file: %#v", c.key, l, c.file)
+		}
+	}
+	if ex := b.Explain("filename"); ex.Candidates[ex.Winner].Origin != IncludeLayer+": "+a {
+		t.Errorf("Wrong explanation for filename. Got: %#v", ex)
+	}
+
+	// In order, each file once.
+	expected := []Layer{
+		{Name: IncludeLayer, File: common},
+		{Name: IncludeLayer, File: base},
+		{Name: IncludeLayer, File: a},
+		{Name: IncludeLayer, File: b2},
+		{Name: ConfigLayer, File: file},
+	}
+	if ls := b.Layers(); !reflect.DeepEqual(ls, expected) {
+		t.Errorf("Wrong layers. Got: %#v, Expected: %#v", ls, expected)
+	}
+	if u := b.Viper().ConfigFileUsed(); u != file {
+		t.Errorf("Wrong config file used. Got: %#v, Expected: %#v", u, file)
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	one := writeConfig(t, dir, "one.yaml", "include: two.yaml\na: 1\n")
+	two := writeConfig(t, dir, "two.yaml", "include: [three.yaml]\nb: 2\n")
+	writeConfig(t, dir, "three.yaml", "include: one.yaml\nc: 3\n")
+	missing := writeConfig(t, dir, "missing.yaml", "include: nowhere.yaml\n")
+	self := writeConfig(t, dir, "self/self.yaml", "include: '*.yaml'\n")
+	bad := writeConfig(t, dir, "bad.yaml", "include: bad/bad.yaml\n")
+	writeConfig(t, dir, "bad/bad.yaml", "a: 1\nb: 2\nc: 3: 4\n")
+
+	b := NewBinder(viper.New())
+	err := b.Init(WithConfigFile(one), WithoutEnv())
+	var ie *IncludeError
+	if !errors.As(err, &ie) {
+		t.Fatalf("Expected an IncludeError for a cycle. Got: %#v", err)
+	}
+	cycle := []string{absPath(one), absPath(two), absPath(filepath.Join(dir, "three.yaml")), absPath(one)}
+	if !reflect.DeepEqual(ie.Cycle, cycle) || ie.Include != "one.yaml" {
+		t.Errorf("Wrong cycle. Got: %#v, Expected: %#v", ie, cycle)
+	}
+
+	err = b.Init(WithConfigFile(missing), WithoutEnv())
+	if !errors.As(err, &ie) || !errors.Is(err, os.ErrNotExist) || ie.File != missing {
+		t.Errorf("Expected an IncludeError for a missing file. Got: %#v", err)
+	}
+
+	err = b.Init(WithConfigFile(self), WithoutEnv())
+	if !errors.As(err, &ie) || len(ie.Cycle) != 2 {
+		t.Errorf("Expected an IncludeError for a file matching its own glob. Got: %#v", err)
+	}
+
+	var pe *ConfigParseError
+	if err = b.Init(WithConfigFile(bad), WithoutEnv()); !errors.As(err, &pe) || pe.Line != 3 {
+		t.Errorf("Expected a ConfigParseError for a bad included file. Got: %#v", err)
+	}
+}
+
+func TestIncludeWatch(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	defer func(d time.Duration) { WatchDelay = d }(WatchDelay)
+	WatchDelay = 20 * time.Millisecond
+
+	file := writeConfig(t, dir, "app.yaml", "include: conf.d/*.yaml\nscreen: app\n")
+	writeConfig(t, dir, "conf.d/a.yaml", "connection: a\n")
+
+	b := NewBinder(viper.New())
+	if err := b.Init(WithConfigFile(file), WithoutEnv()); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+	events := make(chan ReloadEvent, 10)
+	if err := b.Watch(func(ev ReloadEvent) { events <- ev }); err != nil {
+		t.Fatalf("Unexpected error from Watch: %v", err)
+	}
+	defer b.StopWatch()
+
+	type tc struct {
+		name, file, contents string
+		key, e               string
+	}
+	cases := []tc{
+		{name: "included file changed", file: "conf.d/a.yaml", contents: "connection: a-two\n", key: "connection", e: "a-two"},
+		{name: "new file matching the glob", file: "conf.d/b.yaml", contents: "filename: b\n", key: "filename", e: "b"},
+	}
+	for _, c := range cases {
+		writeConfig(t, dir, c.file, c.contents)
+		var ev ReloadEvent
+		select {
+		case ev = <-events:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: Timed out waiting for a reload.", c.name)
+		}
+		if ev.Err != nil {
+			t.Errorf("%s: Unexpected error from reload: %v", c.name, ev.Err)
+		}
+		if v := b.Viper().GetString(c.key); v != c.e {
+			t.Errorf("%s: Wrong value for %q after reload. Got: %#v, Expected: %#v", c.name, c.key, v, c.e)
+		}
+	}
+}
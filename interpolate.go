@@ -0,0 +1,262 @@
+package vconfig
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cast"
+)
+
+/*
+* Interpolation.
+*
+* String values can refer to other values:
+*
+*	data_dir: ${home}/.app
+*	log_dir: ${data_dir}/logs
+*	token: ${env:APP_TOKEN}
+*
+* ${key} is the value of another key, ${env:VAR} an environment variable and
+* ${home} the user's home directory. $${ is a literal ${. A value that's
+* nothing but a reference to a key gets that key's value, whatever its type.
+*
+* References are to the values viper ends up with, after the precedence,
+* so a flag for data_dir changes log_dir too. Viper keeps the values as
+* they were given, and Get, a Key's Get, FillStruct and SecretValue expand them.
+* They're worked out again after every change made through the Binder
+* (Apply, ApplyFromFlags, Set, Reload ...), and a key whose value changes
+* only because of what it refers to gets a ChangeEvent with the expanded values.
+*
+* A reference that can't be expanded, a cycle or a key or environment
+* variable that isn't there, leaves the value as it was. Init, Reload and
+* ApplyFromFlags report them in their errors, and GetE for the key.
+ */
+
+// InterpolationError is a reference in the value of Key that can't be expanded.
+type InterpolationError struct {
+	Key     string
+	Ref     string   // e.g. ${data_dir}
+	Cycle   []string // The keys in the cycle, if there is one, starting and ending with the same key.
+	Problem string
+}
+
+func (e *InterpolationError) Error() string {
+	if len(e.Cycle) > 0 {
+		return fmt.Sprintf("%s: %s refers back to itself: %s", e.Key, e.Ref, strings.Join(e.Cycle, " -> "))
+	}
+	return fmt.Sprintf("%s: %s %s", e.Key, e.Ref, e.Problem)
+}
+
+// expansion is the value of a key with references in it.
+type expansion struct {
+	raw   interface{} // What viper has.
+	value interface{} // The raw value expanded, or raw if it can't be.
+	err   error
+}
+
+// Get returns the value of key with references in it expanded.
+// Nested values are expanded too.
+func Get(key string) interface{} {
+	return std.Get(key)
+}
+
+// Get returns the value of key in this Binder, expanded.
+// See the package level Get.
+func (b *Binder) Get(key string) interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.get(key)
+}
+
+// GetString returns the value of key, expanded, as a string.
+func GetString(key string) string {
+	return std.GetString(key)
+}
+
+// GetString returns the value of key in this Binder, expanded, as a string.
+func (b *Binder) GetString(key string) string {
+	return cast.ToString(b.Get(key))
+}
+
+// GetE returns the value of key, expanded, and the error if it couldn't be.
+func GetE(key string) (interface{}, error) {
+	return std.GetE(key)
+}
+
+// GetE returns the value of key in this Binder, expanded, and the error if it couldn't be.
+func (b *Binder) GetE(key string) (interface{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var errs errorList
+	key = strings.ToLower(key)
+	for _, k := range expansionKeys(b.expanded) {
+		if k == key || strings.HasPrefix(k, key+".") {
+			errs = errs.add(b.expanded[k].err)
+		}
+	}
+	return b.get(key), errs.err()
+}
+
+// get returns the value of key, expanded. b.mu must be held.
+func (b *Binder) get(key string) interface{} {
+	key = strings.ToLower(key)
+	if e, ok := b.expanded[key]; ok {
+		return e.value
+	}
+	v := b.Viper().Get(key)
+	if m, ok := v.(map[string]interface{}); ok && len(b.expanded) > 0 {
+		return b.expandMap(key, m)
+	}
+	return v
+}
+
+// expandMap returns a copy of m, the value of key, with the expanded values in it.
+func (b *Binder) expandMap(key string, m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		fk := joinKey(key, strings.ToLower(k))
+		if sm, ok := v.(map[string]interface{}); ok {
+			c[k] = b.expandMap(fk, sm)
+		} else if e, ok := b.expanded[fk]; ok {
+			c[k] = e.value
+		} else {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+// interpolate expands the values with references in them, queuing events for the keys whose
+// expanded value changed when their own didn't. It returns the references that can't be
+// expanded. b.mu must be held.
+func (b *Binder) interpolate() error {
+	v := b.Viper()
+	ip := &interpolator{raw: make(map[string]interface{}), done: make(map[string]expansion)}
+	for _, k := range v.AllKeys() {
+		ip.raw[k] = v.Get(k)
+	}
+	expanded := make(map[string]expansion)
+	var errs errorList
+	seen := make(map[error]bool)
+	for _, k := range sortedKeys(ip.raw) {
+		if s, ok := ip.raw[k].(string); ok && strings.Contains(s, "${") {
+			e := ip.key(k)
+			expanded[k] = e
+			// Keys that refer to a key that can't be expanded have its error, report it once.
+			if e.err != nil && !seen[e.err] {
+				seen[e.err] = true
+				errs = errs.add(e.err)
+			}
+		}
+	}
+
+	for _, k := range expansionKeys(expanded) {
+		e := expanded[k]
+		if old, ok := b.expanded[k]; ok && reflect.DeepEqual(old.raw, e.raw) {
+			b.changed(k, old.value, e.value, b.sourceOf(k))
+		}
+	}
+	b.expanded = expanded
+	return errs.err()
+}
+
+// interpolator expands the values of keys, remembering those it's done.
+type interpolator struct {
+	raw   map[string]interface{}
+	done  map[string]expansion
+	stack []string // Keys being expanded, to find cycles.
+}
+
+// key expands the value of key.
+func (ip *interpolator) key(key string) expansion {
+	if e, ok := ip.done[key]; ok {
+		return e
+	}
+	raw := ip.raw[key]
+	e := expansion{raw: raw, value: raw}
+	if s, ok := raw.(string); ok {
+		ip.stack = append(ip.stack, key)
+		e.value, e.err = ip.expand(key, s)
+		ip.stack = ip.stack[:len(ip.stack)-1]
+		if e.err != nil {
+			e.value = raw
+		}
+	}
+	ip.done[key] = e
+	return e
+}
+
+// expand returns s, the value of key, with its references expanded.
+func (ip *interpolator) expand(key, s string) (interface{}, error) {
+	if name := strings.TrimSuffix(strings.TrimPrefix(s, "${"), "}"); len(name) == len(s)-3 &&
+		!strings.ContainsAny(name, "${}") {
+		return ip.ref(key, name)
+	}
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' { // $${ is a literal ${.
+			sb.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+		j := strings.Index(s[i:], "}")
+		if j < 0 {
+			return nil, &InterpolationError{Key: key, Ref: s[i:], Problem: "has no closing }"}
+		}
+		v, err := ip.ref(key, s[i+2:i+j])
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(s[:i] + cast.ToString(v))
+		s = s[i+j+1:]
+	}
+}
+
+// ref returns the value name refers to, in the value of key.
+func (ip *interpolator) ref(key, name string) (interface{}, error) {
+	ref := "${" + name + "}"
+	switch {
+	case name == "home":
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, &InterpolationError{Key: key, Ref: ref, Problem: err.Error()}
+		}
+		return home, nil
+	case strings.HasPrefix(name, "env:"):
+		if v, ok := os.LookupEnv(strings.TrimPrefix(name, "env:")); ok {
+			return v, nil
+		}
+		return nil, &InterpolationError{Key: key, Ref: ref, Problem: "isn't set"}
+	}
+	name = strings.ToLower(name)
+	if _, ok := ip.raw[name]; !ok {
+		return nil, &InterpolationError{Key: key, Ref: ref, Problem: "isn't a key"}
+	}
+	for i, k := range ip.stack {
+		if k == name {
+			cycle := append(append([]string{}, ip.stack[i:]...), name)
+			return nil, &InterpolationError{Key: key, Ref: ref, Cycle: cycle}
+		}
+	}
+	e := ip.key(name)
+	return e.value, e.err
+}
+
+// expansionKeys returns the keys in m, sorted.
+func expansionKeys(m map[string]expansion) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,168 @@
+package vconfig
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var testLogDir = NewKey("log_dir", "Where the logs go.", "")
+
+func TestInterpolation(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := writeConfig(t, dir, "app.yaml", `data_dir: /var/app
+log_dir: ${data_dir}/logs
+log_file: ${log_dir}/app.log
+cache: ${home}/.cache
+user: ${env:INTERPTEST_USER}
+port: 8080
+listen: ":${port}"
+server:
+  port: ${port}
+  name: ${user}@${data_dir}
+literal: $${data_dir}
+`)
+	defer setEnv(t, map[string]string{"INTERPTEST_USER": "app"})()
+	home, _ := homedir.Dir()
+
+	b := NewBinder(viper.New())
+	if err := b.Init(WithConfigFile(file), WithoutEnv()); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+
+	type tc struct {
+		key string
+		e   interface{}
+	}
+	cases := []tc{
+		{key: "log_dir", e: "/var/app/logs"},
+		{key: "log_file", e: "/var/app/logs/app.log"},
+		{key: "cache", e: home + "/.cache"},
+		{key: "user", e: "app"},
+		{key: "listen", e: ":8080"},
+		{key: "server.port", e: 8080},
+		{key: "Server.Name", e: "app@/var/app"},
+		{key: "literal", e: "${data_dir}"},
+		{key: "data_dir", e: "/var/app"},
+		{key: "server", e: map[string]interface{}{"port": 8080, "name": "app@/var/app"}},
+	}
+	for _, c := range cases {
+		if v := b.Get(c.key); !reflect.DeepEqual(v, c.e) {
+			t.Errorf("Wrong value for %q. Got: %#v, Expected: %#v", c.key, v, c.e)
+		}
+	}
+	if v := b.Viper().GetString("log_dir"); v != "${data_dir}/logs" {
+		t.Errorf("Viper should keep the reference. Got: %#v", v)
+	}
+
+	// A flag for data_dir changes what refers to it, and goes away with the flag.
+	var events []ChangeEvent
+	b.OnAnyChange(func(ev ChangeEvent) { events = append(events, ev) })
+	pflags := pflag.NewFlagSet("Interpolation", pflag.ContinueOnError)
+	pflags.String("data-dir", "", "")
+	b.Bind("data_dir", pflags.Lookup("data-dir"))
+	pflags.Parse([]string{"--data-dir", "/tmp/app"})
+	if err := b.ApplyFromFlags(pflags); err != nil {
+		t.Fatalf("Unexpected error from ApplyFromFlags: %v", err)
+	}
+	if v := b.GetString("log_file"); v != "/tmp/app/logs/app.log" {
+		t.Errorf("Wrong value after ApplyFromFlags. Got: %#v, Expected: %#v", v, "/tmp/app/logs/app.log")
+	}
+	if v := testLogDir.GetFrom(b); v != "/tmp/app/logs" {
+		t.Errorf("Wrong value from a Key. Got: %#v, Expected: %#v", v, "/tmp/app/logs")
+	}
+	changed := make(map[string]interface{})
+	for _, ev := range events {
+		changed[ev.Key] = ev.New
+	}
+	for k, e := range map[string]interface{}{"data_dir": "/tmp/app", "log_dir": "/tmp/app/logs", "log_file": "/tmp/app/logs/app.log", "server.name": "app@/tmp/app"} {
+		if v, ok := changed[k]; !ok || v != e {
+			t.Errorf("Wrong change event for %q. Got: %#v, Expected: %#v", k, v, e)
+		}
+	}
+	b.Apply()
+	if v := b.GetString("log_dir"); v != "/var/app/logs" {
+		t.Errorf("Wrong value after Apply. Got: %#v, Expected: %#v", v, "/var/app/logs")
+	}
+
+	// And through a reload.
+	writeConfig(t, dir, "app.yaml", "data_dir: /srv\nlog_dir: ${data_dir}/logs\n")
+	if err := b.Reload(); err != nil {
+		t.Fatalf("Unexpected error from Reload: %v", err)
+	}
+	if v := b.GetString("log_dir"); v != "/srv/logs" {
+		t.Errorf("Wrong value after Reload. Got: %#v, Expected: %#v", v, "/srv/logs")
+	}
+}
+
+func TestInterpolationErrors(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := writeConfig(t, dir, "app.yaml", `a: ${b}/a
+b: ${c}/b
+c: ${a}/c
+d: ${a}/d
+self: x${self}
+missing: ${nowhere}
+unset: ${env:INTERPTEST_UNSET}
+open: ${a
+fine: ok
+`)
+
+	b := NewBinder(viper.New())
+	err := b.Init(WithConfigFile(file), WithoutEnv())
+	var ies []*InterpolationError
+	var el errorList
+	if !errors.As(err, &el) {
+		t.Fatalf("Expected a list of errors from Init. Got: %v", err)
+	}
+	for _, e := range el {
+		var ie *InterpolationError
+		if !errors.As(e, &ie) {
+			t.Fatalf("Expected an InterpolationError. Got: %#v", e)
+		}
+		ies = append(ies, ie)
+	}
+
+	type tc struct {
+		key, ref string
+		cycle    []string
+		problem  string
+	}
+	expected := []tc{
+		{key: "c", ref: "${a}", cycle: []string{"a", "b", "c", "a"}},
+		{key: "missing", ref: "${nowhere}", problem: "isn't a key"},
+		{key: "open", ref: "${a", problem: "has no closing }"},
+		{key: "self", ref: "${self}", cycle: []string{"self", "self"}},
+		{key: "unset", ref: "${env:INTERPTEST_UNSET}", problem: "isn't set"},
+	}
+	if len(ies) != len(expected) {
+		t.Fatalf("Wrong number of errors. Got: %v, Expected: %#v", err, expected)
+	}
+	for i, e := range expected {
+		ie := ies[i]
+		if ie.Key != e.key || ie.Ref != e.ref || !reflect.DeepEqual(ie.Cycle, e.cycle) || ie.Problem != e.problem {
+			t.Errorf("Wrong error %d. Got: %#v, Expected: %#v", i, ie, e)
+		}
+	}
+
+	// Values that can't be expanded are left alone, and GetE says why.
+	if v, err := b.GetE("d"); v != "${a}/d" || err == nil {
+		t.Errorf("Wrong value for a key referring to a cycle. Got: %#v, %v", v, err)
+	}
+	if v, err := b.GetE("fine"); v != "ok" || err != nil {
+		t.Errorf("Wrong value for a key without references. Got: %#v, %v", v, err)
+	}
+
+	// Breaking the cycle fixes it.
+	b.Set("c", "c")
+	if v, err := b.GetE("d"); v != "c/b/a/d" || err != nil {
+		t.Errorf("Wrong value once the cycle is broken. Got: %#v, %v", v, err)
+	}
+}
@@ -0,0 +1,246 @@
+package vconfig
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*
+* Journal of interactive changes.
+*
+* Each Set and Toggle (and so SetDebug, ToggleDebug ...) is recorded,
+* and can be undone and redone. A new change after an Undo drops
+* the changes that could have been redone.
+*
+* With WithJournal() each change, undo and redo is also appended to a
+* file next to the history file, one JSON object per line, which
+* ReadJournal reads back and Replay applies. The values of secret keys
+* are written as Redacted, and those changes aren't replayed.
+ */
+
+// Journal operations.
+const (
+	OpSet  = "set"
+	OpUndo = "undo"
+	OpRedo = "redo"
+)
+
+// JournalEntry records a change.
+type JournalEntry struct {
+	Time   time.Time   `json:"time"`
+	Op     string      `json:"op"`
+	Key    string      `json:"key"`
+	Old    interface{} `json:"old"` // The value of Key before.
+	New    interface{} `json:"new"` // The value of Key after.
+	Source Source      `json:"source"`
+	Value  interface{} `json:"value"` // Given to Set, nil when a Set was removed.
+
+	prev interface{} // The Set value before, for Undo.
+}
+
+// WithJournal appends each change made through Set, Toggle, Undo and Redo
+// to a file next to the history file, HistoryFile if it's set.
+func WithJournal() Option {
+	return func(o *options) { o.journal = true }
+}
+
+// WithJournalFile appends each change made through Set, Toggle, Undo and Redo to path.
+func WithJournalFile(path string) Option {
+	return func(o *options) {
+		o.journal = true
+		o.journalFile = path
+	}
+}
+
+// journalPath is where the options put the journal, "" if it's not kept.
+func (o *options) journalPath() string {
+	if o == nil || !o.journal {
+		return ""
+	}
+	if o.journalFile != "" {
+		return o.journalFile
+	}
+	name := "." + o.appName + "_journal"
+	if o.xdg {
+		name = "journal"
+	}
+	hf := HistoryFile
+	if hf == "" {
+		hf = o.historyFile()
+	}
+	return filepath.Join(filepath.Dir(hf), name)
+}
+
+// History returns the changes that have been made and not undone, oldest first.
+func History() []JournalEntry {
+	return std.History()
+}
+
+// History returns the changes that have been made and not undone, oldest first.
+func (b *Binder) History() []JournalEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]JournalEntry(nil), b.journal[:b.journalPos]...)
+}
+
+// Undo reverts the last change that hasn't been undone.
+// It returns false if there's nothing to undo.
+func Undo() (bool, error) {
+	return std.Undo()
+}
+
+// Undo reverts the last change that hasn't been undone.
+// See the package level Undo.
+func (b *Binder) Undo() (bool, error) {
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.journalPos == 0 {
+		return false, nil
+	}
+	je := b.journal[b.journalPos-1]
+	if err := b.journalSet(OpUndo, je.Key, je.prev, b.journalPos-1); err != nil {
+		return true, err
+	}
+	return true, b.followProfile()
+}
+
+// Redo makes the last change undone again.
+// It returns false if there's nothing to redo.
+func Redo() (bool, error) {
+	return std.Redo()
+}
+
+// Redo makes the last change undone again.
+// See the package level Redo.
+func (b *Binder) Redo() (bool, error) {
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.journalPos == len(b.journal) {
+		return false, nil
+	}
+	je := b.journal[b.journalPos]
+	if err := b.journalSet(OpRedo, je.Key, je.Value, b.journalPos+1); err != nil {
+		return true, err
+	}
+	return true, b.followProfile()
+}
+
+// Replay makes the Sets recorded in entries, e.g. from ReadJournal,
+// as a single change each.
+func Replay(entries []JournalEntry) error {
+	return std.Replay(entries)
+}
+
+// Replay makes the Sets recorded in entries.
+// See the package level Replay.
+func (b *Binder) Replay(entries []JournalEntry) error {
+	var errs errorList
+	for _, je := range entries {
+		if je.Value == Redacted && b.IsSecret(je.Key) {
+			continue
+		}
+		errs = errs.add(b.Set(je.Key, je.Value))
+	}
+	return errs.err()
+}
+
+// ReadJournal reads the entries in a journal file.
+func ReadJournal(path string) (entries []JournalEntry, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		var je JournalEntry
+		if err := json.Unmarshal(s.Bytes(), &je); err != nil {
+			return entries, fmt.Errorf("journal %s line %d: %w", path, n, err)
+		}
+		entries = append(entries, je)
+	}
+	return entries, s.Err()
+}
+
+// setValue returns the value Set has given key, if any. b.mu must be held.
+func (b *Binder) setValue(key string) interface{} {
+	if bf, ok := b.bbm[key]; ok {
+		return bf.value
+	}
+	return b.sets[strings.ToLower(key)]
+}
+
+// record makes a Set and adds it to the journal. b.mu must be held.
+func (b *Binder) record(key string, value interface{}) error {
+	prev := b.setValue(key)
+	old := b.Viper().Get(key)
+	if err := b.set(key, value); err != nil {
+		return err
+	}
+	je := b.entry(OpSet, key, old)
+	je.prev = prev
+	b.journal = append(b.journal[:b.journalPos], je)
+	b.journalPos++
+	return b.persist(je)
+}
+
+// journalSet makes a Set for Undo or Redo, and once it's made moves
+// the journal to pos. b.mu must be held.
+func (b *Binder) journalSet(op, key string, value interface{}, pos int) error {
+	old := b.Viper().Get(key)
+	if err := b.set(key, value); err != nil {
+		return err
+	}
+	b.journalPos = pos
+	return b.persist(b.entry(op, key, old))
+}
+
+// entry describes the change to key just made. b.mu must be held.
+func (b *Binder) entry(op, key string, old interface{}) JournalEntry {
+	return JournalEntry{
+		Time:   time.Now(),
+		Op:     op,
+		Key:    key,
+		Old:    old,
+		New:    b.Viper().Get(key),
+		Source: b.sourceOf(key),
+		Value:  b.setValue(key),
+	}
+}
+
+// persist appends je to the journal file, if there is one. b.mu must be held.
+func (b *Binder) persist(je JournalEntry) error {
+	path := b.opts.journalPath()
+	if path == "" {
+		return nil
+	}
+	if b.isSecret(je.Key) {
+		je.Old, je.New, je.Value = b.redact(je.Key, je.Old), b.redact(je.Key, je.New), b.redact(je.Key, je.Value)
+	}
+	line, err := json.Marshal(je)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
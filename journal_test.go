@@ -0,0 +1,139 @@
+package vconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestUndoRedo(t *testing.T) {
+	b := NewBinder(viper.New())
+	v := b.Viper()
+	pflags := pflag.NewFlagSet("Journal", pflag.ContinueOnError)
+	pflags.Int("count", 0, "")
+	b.Bind("count", pflags.Lookup("count"))
+
+	b.Set("count", "1")
+	b.Set("count", 2)
+	b.Toggle(DebugKey)
+	b.Set("screen", "set")
+
+	type tc struct {
+		op    func() (bool, error)
+		ok    bool
+		count interface{}
+		debug bool
+		set   bool // screen is set
+		n     int  // len(History())
+	}
+	cases := []tc{
+		{op: b.Undo, ok: true, count: 2, debug: true, set: false, n: 3},
+		{op: b.Undo, ok: true, count: 2, debug: false, set: false, n: 2},
+		{op: b.Undo, ok: true, count: 1, debug: false, set: false, n: 1},
+		{op: b.Redo, ok: true, count: 2, debug: false, set: false, n: 2},
+		{op: b.Undo, ok: true, count: 1, debug: false, set: false, n: 1},
+		{op: b.Undo, ok: true, count: nil, debug: false, set: false, n: 0},
+		{op: b.Undo, ok: false, count: nil, debug: false, set: false, n: 0},
+		{op: b.Redo, ok: true, count: 1, debug: false, set: false, n: 1},
+	}
+	for i, c := range cases {
+		ok, err := c.op()
+		if err != nil {
+			t.Fatalf("Unexpected error from case %d: %v", i, err)
+		}
+		if ok != c.ok || v.Get("count") != c.count || b.Debug() != c.debug || v.IsSet("screen") != c.set || len(b.History()) != c.n {
+			t.Errorf("Wrong state after case %d. Got: %v, %#v, %v, %v, %d, Expected: %#v", i, ok, v.Get("count"), b.Debug(), v.IsSet("screen"), len(b.History()), c)
+		}
+	}
+
+	// A new change drops the ones that could be redone.
+	b.Set("count", 5)
+	if ok, _ := b.Redo(); ok {
+		t.Errorf("Redo after a new change should do nothing.")
+	}
+	h := b.History()
+	if len(h) != 2 {
+		t.Fatalf("Wrong history length. Got: %d, Expected: %d", len(h), 2)
+	}
+	je := h[1]
+	if je.Op != OpSet || je.Key != "count" || je.Old != 1 || je.New != 5 || je.Source != SourceSet || je.Time.IsZero() {
+		t.Errorf("Wrong history entry. Got: %#v", je)
+	}
+
+	// An Undo that can't be made leaves the journal where it was.
+	b = NewBinder(viper.New())
+	b.Set("count", "abc")
+	b.Set("count", "def")
+	b.Bind("count", pflags.Lookup("count")) // "abc" can't be an int.
+	if ok, err := b.Undo(); !ok || err == nil {
+		t.Errorf("Expected an error from Undo. Got: %v, %v", ok, err)
+	}
+	if n := len(b.History()); n != 2 || b.Viper().Get("count") != "def" {
+		t.Errorf("Wrong state after a failed Undo. Got: %d, %#v", n, b.Viper().Get("count"))
+	}
+}
+
+func TestJournalFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := writeConfig(t, dir, "app.yaml", "screen: file\n")
+	journal := filepath.Join(dir, "state", "journal")
+
+	b := NewBinder(viper.New())
+	if err := b.Init(WithConfigFile(file), WithoutEnv(), WithJournalFile(journal)); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+	b.Set("screen", "one")
+	b.Set("screen", "two")
+	b.Undo()
+	b.Redo()
+	b.Undo()
+
+	entries, err := ReadJournal(journal)
+	if err != nil {
+		t.Fatalf("Unexpected error from ReadJournal: %v", err)
+	}
+	type tc struct {
+		op, old, new string
+	}
+	expected := []tc{
+		{OpSet, "file", "one"},
+		{OpSet, "one", "two"},
+		{OpUndo, "two", "one"},
+		{OpRedo, "one", "two"},
+		{OpUndo, "two", "one"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Wrong number of entries. Got: %#v, Expected: %#v", entries, expected)
+	}
+	for i, e := range expected {
+		je := entries[i]
+		if je.Op != e.op || je.Old != e.old || je.New != e.new || je.Key != "screen" {
+			t.Errorf("Wrong entry %d. Got: %#v, Expected: %#v", i, je, e)
+		}
+	}
+
+	// Replaying the session gets to the same place.
+	rb := NewBinder(viper.New())
+	if err := rb.Replay(entries); err != nil {
+		t.Fatalf("Unexpected error from Replay: %v", err)
+	}
+	if g := rb.Viper().GetString("screen"); g != "one" {
+		t.Errorf("Wrong value after replay. Got: %#v, Expected: %#v", g, "one")
+	}
+
+	// The default is next to the history file.
+	defer func(h string) { HistoryFile = h }(HistoryFile)
+	HistoryFile = ""
+	o := newOptions(WithAppName("app"), WithJournal())
+	if p := o.journalPath(); p != ".app_journal" {
+		t.Errorf("Wrong journal path. Got: %#v, Expected: %#v", p, ".app_journal")
+	}
+	HistoryFile = filepath.Join("state", "app_history")
+	if p, e := o.journalPath(), filepath.Join("state", ".app_journal"); p != e {
+		t.Errorf("Wrong journal path with HistoryFile set. Got: %#v, Expected: %#v", p, e)
+	}
+}
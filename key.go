@@ -0,0 +1,235 @@
+package vconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/spf13/pflag"
+)
+
+/*
+* Typed keys.
+*
+* A Key carries the type of its value along with the name, so
+*
+*	var Port = NewKey("server.port", "The port to listen on.", 8080)
+*	...
+*	p := Port.Get() // an int
+*
+* can't be read as the wrong type, and the compiler catches a string
+* passed to Port.Set. Keys are declared once, at the package level, and
+* register themselves in a catalog that lists every key the app has.
+*
+* The value is whatever viper has for the name, converted to the key's type
+* the way FillStruct converts fields, or the Default if there's none.
+ */
+
+// Key is a config key whose value is a T.
+type Key[T any] struct {
+	Name        string
+	Default     T
+	Description string
+	Validate    func(T) error // Checks values given to Set, if not nil.
+	Secret      bool          // Redacted when printed, and resolved by Get, see secret.go.
+}
+
+// BoolKey is a Key for a bool, which can also be toggled.
+type BoolKey struct {
+	*Key[bool]
+}
+
+// KeyInfo describes a key in the catalog.
+type KeyInfo struct {
+	Name        string
+	Type        reflect.Type
+	Default     interface{}
+	Description string
+	Secret      bool
+}
+
+var (
+	catalogMu sync.Mutex
+	catalog   = make(map[string]KeyInfo)
+)
+
+// NewKey makes a key and adds it to the catalog.
+// It panics if there's already a key with the name, as flag does for flags.
+func NewKey[T any](name, description string, def T) *Key[T] {
+	k := &Key[T]{Name: name, Default: def, Description: description}
+	register(KeyInfo{Name: name, Type: reflect.TypeOf(&def).Elem(), Default: def, Description: description})
+	return k
+}
+
+// NewBoolKey makes a bool key and adds it to the catalog.
+func NewBoolKey(name, description string, def bool) BoolKey {
+	return BoolKey{NewKey(name, description, def)}
+}
+
+func register(ki KeyInfo) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	if _, ok := catalog[ki.Name]; ok {
+		panic(fmt.Sprintf("vconfig: key %q declared twice", ki.Name))
+	}
+	catalog[ki.Name] = ki
+}
+
+// Catalog returns all the keys that have been declared, sorted by name.
+func Catalog() []KeyInfo {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	kis := make([]KeyInfo, 0, len(catalog))
+	for _, ki := range catalog {
+		kis = append(kis, ki)
+	}
+	sort.Slice(kis, func(i, j int) bool { return kis[i].Name < kis[j].Name })
+	return kis
+}
+
+// WithValidator sets the function that checks values given to Set, returning the key.
+func (k *Key[T]) WithValidator(fn func(T) error) *Key[T] {
+	k.Validate = fn
+	return k
+}
+
+// AsSecret marks the key as secret, returning the key. See secret.go.
+func (k *Key[T]) AsSecret() *Key[T] {
+	k.Secret = true
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	if ki, ok := catalog[k.Name]; ok {
+		ki.Secret = true
+		catalog[k.Name] = ki
+	}
+	return k
+}
+
+// Get returns the value of the key.
+func (k *Key[T]) Get() T {
+	return k.GetFrom(std)
+}
+
+// GetFrom returns the value of the key in the Binder b.
+// Keys that are kept in the snapshot, like debug, are read without waiting for the lock.
+// The value of a secret key is resolved as it is by SecretValue,
+// if it can't be the Default is returned.
+func (k *Key[T]) GetFrom(b *Binder) T {
+	var v interface{}
+	secret := k.Secret
+	if isHot(k.Name) {
+		v = b.hot(k.Name)
+	} else {
+		b.mu.Lock()
+		v = b.get(k.Name)
+		secret = secret || b.isSecret(k.Name)
+		b.mu.Unlock()
+	}
+	if s, ok := v.(string); ok && secret {
+		rs, err := b.resolve(k.Name, s)
+		if err != nil {
+			return k.Default
+		}
+		v = rs
+	}
+	return k.convert(v)
+}
+
+// convert gives the key's value for v, or the default if v is nil or can't be converted.
+func (k *Key[T]) convert(v interface{}) T {
+	if v == nil {
+		return k.Default
+	}
+	if t, ok := v.(T); ok {
+		return t
+	}
+	// No debug output here, Debug is a Key.
+	fv, err := fieldValue(v, reflect.TypeOf(&k.Default).Elem())
+	if err != nil {
+		return k.Default
+	}
+	return fv.Interface().(T)
+}
+
+// Set sets the value of the key, after checking it with the key's validator.
+func (k *Key[T]) Set(v T) error {
+	return k.SetOn(std, v)
+}
+
+// SetOn sets the value of the key in the Binder b.
+// See Set.
+func (k *Key[T]) SetOn(b *Binder, v T) error {
+	if k.Validate != nil {
+		if err := k.Validate(v); err != nil {
+			return fmt.Errorf("%s: %w", k.Name, err)
+		}
+	}
+	return b.Set(k.Name, v)
+}
+
+// Bind binds the key to a flag.
+// It's an error for the flag to be of another type, or already bound to another key.
+func (k *Key[T]) Bind(f *pflag.Flag) (*BindFlag, error) {
+	return k.BindOn(std, f)
+}
+
+// BindOn binds the key to a flag in the Binder b.
+// See Bind.
+func (k *Key[T]) BindOn(b *Binder, f *pflag.Flag) (*BindFlag, error) {
+	if f != nil {
+		if ft, ok := flagType(reflect.TypeOf(&k.Default).Elem()); ok && ft != f.Value.Type() {
+			return nil, fmt.Errorf("can't bind %s flag --%s to %s key %q", f.Value.Type(), f.Name, ft, k.Name)
+		}
+	}
+	return b.BindE(k.Name, f)
+}
+
+// Toggle flips the value of the key, returning the new value.
+// See ToggleE.
+func (k BoolKey) Toggle() bool {
+	return k.ToggleOn(std)
+}
+
+// ToggleOn flips the value of the key in the Binder b.
+func (k BoolKey) ToggleOn(b *Binder) bool {
+	v, _ := k.ToggleOnE(b)
+	return v
+}
+
+// ToggleE flips the value of the key, returning its value. The value is
+// left alone if the new one fails the key's validator or the schema,
+// as with Set.
+func (k BoolKey) ToggleE() (bool, error) {
+	return k.ToggleOnE(std)
+}
+
+// ToggleOnE flips the value of the key in the Binder b.
+// See ToggleE.
+func (k BoolKey) ToggleOnE(b *Binder) (bool, error) {
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	v := k.convert(b.Viper().Get(k.Name))
+	if k.Validate != nil {
+		if err := k.Validate(!v); err != nil {
+			return v, fmt.Errorf("%s: %w", k.Name, err)
+		}
+	}
+	if err := b.checkValue(k.Name, !v); err != nil {
+		return v, err
+	}
+	if err := b.record(k.Name, !v); err != nil {
+		return k.convert(b.Viper().Get(k.Name)), err
+	}
+	return !v, nil
+}
+
+func isHot(k string) bool {
+	for _, hk := range hotKeys {
+		if hk == k {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package vconfig
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var (
+	testPort    = NewKey("test.port", "The port.", 8080)
+	testTimeout = NewKey("test.timeout", "How long to wait.", time.Second)
+	testQuiet   = NewBoolKey("test.quiet", "Say less.", true)
+)
+
+func TestKey(t *testing.T) {
+	b := NewBinder(viper.New())
+	v := b.Viper()
+	testPort.WithValidator(func(p int) error {
+		if p <= 0 || p > 65535 {
+			return fmt.Errorf("%d is not a port", p)
+		}
+		return nil
+	})
+	defer testPort.WithValidator(nil)
+
+	// Defaults, then converted values.
+	if p := testPort.GetFrom(b); p != 8080 {
+		t.Errorf("Wrong default. Got: %#v, Expected: %#v", p, 8080)
+	}
+	v.Set("test.port", "9090")
+	v.Set("test.timeout", "1m")
+	if p := testPort.GetFrom(b); p != 9090 {
+		t.Errorf("Wrong converted value. Got: %#v, Expected: %#v", p, 9090)
+	}
+	if d := testTimeout.GetFrom(b); d != time.Minute {
+		t.Errorf("Wrong converted value. Got: %#v, Expected: %#v", d, time.Minute)
+	}
+	v.Set("test.port", "not a port")
+	if p := testPort.GetFrom(b); p != 8080 {
+		t.Errorf("Bad value should give the default. Got: %#v, Expected: %#v", p, 8080)
+	}
+
+	// Set validates.
+	if err := testPort.SetOn(b, 7070); err != nil {
+		t.Errorf("Unexpected error from Set: %v", err)
+	}
+	if err := testPort.SetOn(b, -1); err == nil {
+		t.Errorf("Expected an error setting an invalid value")
+	}
+	if p := testPort.GetFrom(b); p != 7070 {
+		t.Errorf("Wrong value after Set. Got: %#v, Expected: %#v", p, 7070)
+	}
+
+	// Toggle starts from the default.
+	if q := testQuiet.ToggleOn(b); q != false {
+		t.Errorf("Wrong toggle. Got: %#v, Expected: %#v", q, false)
+	}
+	if q := testQuiet.ToggleOn(b); q != true {
+		t.Errorf("Wrong toggle. Got: %#v, Expected: %#v", q, true)
+	}
+
+	// Bind checks the flag type.
+	pflags := pflag.NewFlagSet("Key", pflag.ContinueOnError)
+	pflags.Int("port", 0, "")
+	pflags.String("name", "", "")
+	if _, err := testPort.BindOn(b, pflags.Lookup("port")); err != nil {
+		t.Errorf("Unexpected error from Bind: %v", err)
+	}
+	if _, err := testPort.BindOn(b, pflags.Lookup("name")); err == nil {
+		t.Errorf("Expected an error binding a string flag to an int key")
+	}
+
+	// Debug and Verbose are keys too.
+	DebugSetting.SetOn(b, true)
+	if !b.Debug() || b.Verbose() {
+		t.Errorf("Wrong debug and verbose. Got: %t %t, Expected: true false", b.Debug(), b.Verbose())
+	}
+	DebugSetting.SetOn(b, false)
+}
+
+func TestCatalog(t *testing.T) {
+	found := make(map[string]KeyInfo)
+	for _, ki := range Catalog() {
+		found[ki.Name] = ki
+	}
+	for _, k := range []string{DebugKey, VerboseKey, "test.port", "test.timeout", "test.quiet"} {
+		if _, ok := found[k]; !ok {
+			t.Errorf("Key %q is missing from the catalog", k)
+		}
+	}
+	if ki := found["test.timeout"]; ki.Type.String() != "time.Duration" || ki.Default != time.Second {
+		t.Errorf("Wrong catalog entry. Got: %#v", ki)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Declaring a key twice should panic")
+		}
+	}()
+	NewKey(DebugKey, "Again.", false)
+}
@@ -0,0 +1,336 @@
+package vconfig
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+/*
+* Layered config files.
+*
+* With WithLayeredConfig() every layer that exists is read, and each
+* is deep merged over the ones before it:
+*
+*   system:   /etc/<name>.<ext>
+*   user:     ~/.<name>.<ext>
+*   project:  ./<name>.<ext>
+*   explicit: the file from WithConfigFile (e.g. --config)
+*
+* Values from flags and Set are kept in viper's override layer,
+* so they stay on top of all of the files, including after Reload.
+ */
+
+// Names of the default config layers.
+const (
+	SystemLayer   = "system"
+	UserLayer     = "user"
+	ProjectLayer  = "project"
+	ExplicitLayer = "explicit"
+	ConfigLayer   = "config" // The one file read when not layering.
+)
+
+// Layer is a config file merged into the configuration.
+type Layer struct {
+	Name string
+	File string
+}
+
+// loadedLayer is a Layer that's been read.
+type loadedLayer struct {
+	Layer
+	keys     []string               // Every key (flattened with dots) the file sets.
+	settings map[string]interface{} // What the file sets, as nested maps.
+}
+
+// WithLayeredConfig reads the system, user, project and explicit
+// config files, each merged over the ones before.
+func WithLayeredConfig() Option {
+	return func(o *options) { o.layered = true }
+}
+
+// WithLayers reads the layers given, in order, each merged over the ones before.
+// A File without an extension is looked for with each of the supported extensions.
+// Missing files are skipped.
+func WithLayers(layers ...Layer) Option {
+	return func(o *options) {
+		o.layered = true
+		o.layers = append([]Layer(nil), layers...)
+	}
+}
+
+// configLayers returns the layers to read in order, lowest precedence first.
+func (o *options) configLayers() ([]Layer, error) {
+	if o.layers != nil {
+		layers := make([]Layer, len(o.layers))
+		for i, l := range o.layers {
+			f, err := homedir.Expand(l.File)
+			if err != nil {
+				return nil, &HomeDirError{Err: err}
+			}
+			layers[i] = Layer{Name: l.Name, File: f}
+		}
+		return layers, nil
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return nil, &HomeDirError{Err: err}
+	}
+	layers := []Layer{
+		{Name: SystemLayer, File: filepath.Join("/etc", o.configName)},
+		{Name: UserLayer, File: filepath.Join(home, "."+o.configName)},
+		{Name: ProjectLayer, File: o.configName},
+	}
+	if o.configFile != "" {
+		layers = append(layers, Layer{Name: ExplicitLayer, File: o.configFile})
+	}
+	return layers, nil
+}
+
+// findLayerFile returns the file for l, trying each extension if l.File doesn't have one.
+func (o *options) findLayerFile(l Layer, d *Discovery) string {
+	if ext := strings.TrimPrefix(filepath.Ext(l.File), "."); ext != "" && stringIn(ext, viper.SupportedExts) {
+		d.Candidates = append(d.Candidates, l.File)
+		if isFile(l.File) {
+			return l.File
+		}
+		return ""
+	}
+	for _, ext := range viper.SupportedExts {
+		f := l.File + "." + ext
+		d.Candidates = append(d.Candidates, f)
+		if isFile(f) {
+			return f
+		}
+	}
+	if o.configType != "" {
+		d.Candidates = append(d.Candidates, l.File)
+		if isFile(l.File) {
+			return l.File
+		}
+	}
+	return ""
+}
+
+// load reads the config file(s) for the options from the last Init into viper.
+// Must be called with b.mu held.
+func (b *Binder) load() error {
+	// Secrets are resolved again from the new config.
+	b.ForgetSecrets()
+	if b.opts.layered {
+		return b.loadLayers()
+	}
+
+	o := b.opts
+	d, paths, err := o.discover()
+	b.discovery = d
+	if b.Debug() {
+		fmt.Printf("Looked for config file in: %q\n", d.Candidates)
+	}
+	if err != nil {
+		return err
+	}
+	// Read it on its own so we know what came from the file, and what it includes.
+	r := newIncludeReader(o, b.Debug())
+	found, err := r.read(Layer{Name: ConfigLayer, File: d.Used}, paths)
+	if err != nil {
+		return err
+	}
+	merged := make(map[string]interface{})
+	for _, l := range found {
+		mergeSettings(merged, l.settings)
+	}
+	b.includes = r.globs
+	return b.install(d.Used, merged, found)
+}
+
+// loadLayers reads each of the layers that exist. Viper is only changed
+// if they can all be read.
+func (b *Binder) loadLayers() error {
+	o := b.opts
+	layers, err := o.configLayers()
+	if err != nil {
+		return err
+	}
+
+	// Read them all first.
+	var d Discovery
+	var found []loadedLayer
+	r := newIncludeReader(o, b.Debug())
+	for _, l := range layers {
+		f := o.findLayerFile(l, &d)
+		if f == "" {
+			if l.Name == ExplicitLayer {
+				b.discovery = d
+				return &ConfigNotFoundError{File: l.File}
+			}
+			continue
+		}
+		ls, err := r.read(Layer{Name: l.Name, File: f}, nil)
+		if err != nil {
+			b.discovery = d
+			return err
+		}
+		found = append(found, ls...)
+	}
+	if len(found) == 0 {
+		b.discovery = d
+		stems := make([]string, len(layers))
+		for i, l := range layers {
+			stems[i] = l.File
+		}
+		return &ConfigNotFoundError{Name: o.configName, Paths: stems}
+	}
+
+	// Merge them ourselves, viper won't replace a value with one of a different type.
+	merged := make(map[string]interface{})
+	for _, l := range found {
+		mergeSettings(merged, l.settings)
+	}
+	// Report the file with the highest precedence as the one used.
+	d.Used = found[len(found)-1].File
+	b.discovery = d
+	b.includes = r.globs
+	if err := b.install(d.Used, merged, found); err != nil {
+		return err
+	}
+	if b.Debug() {
+		fmt.Printf("Merged config files: %q\n", d.Candidates)
+	}
+	return nil
+}
+
+// Reload reads the config file(s) again using the options from the last Init.
+// Values from Set and bound flags stay in place.
+func Reload() error {
+	return std.Reload()
+}
+
+// Reload reads the config file(s) again using the options from the last Init.
+// See the package level Reload.
+func (b *Binder) Reload() error {
+	if b.Debug() {
+		pef()
+		defer pxf()
+	}
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.refresh()
+	if b.opts == nil {
+		return fmt.Errorf("reload before Init")
+	}
+	before := b.settings()
+	if err := b.loadChecked(true); err != nil {
+		return err
+	}
+	err := b.loadEnv()
+	if pErr := b.loadProfile(); err == nil {
+		err = pErr
+	}
+	b.changedSettings(before, b.settings())
+	if iErr := b.interpolate(); err == nil {
+		err = iErr
+	}
+	return err
+}
+
+// Layers returns the config files read by the last Init or Reload,
+// lowest precedence first.
+func Layers() []Layer {
+	return std.Layers()
+}
+
+// Layers returns the config files read by the last Init or Reload,
+// lowest precedence first.
+func (b *Binder) Layers() []Layer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ls := make([]Layer, len(b.layers))
+	for i, l := range b.layers {
+		ls[i] = l.Layer
+	}
+	return ls
+}
+
+// LayerFor returns the config file layer that the value for key comes from.
+// The value may be hidden by an environment variable, flag or Set;
+// this only reports on the files.
+func LayerFor(key string) (Layer, bool) {
+	return std.LayerFor(key)
+}
+
+// LayerFor returns the config file layer that the value for key comes from.
+// See the package level LayerFor.
+func (b *Binder) LayerFor(key string) (Layer, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.layerFor(key)
+}
+
+func (b *Binder) layerFor(key string) (Layer, bool) {
+	key = strings.ToLower(key)
+	for i := len(b.layers) - 1; i >= 0; i-- {
+		l := b.layers[i]
+		for _, k := range l.keys {
+			// A key in a higher layer that is or contains key hides the layers below.
+			if k == key || strings.HasPrefix(k, key+".") {
+				return l.Layer, true
+			}
+			// A higher layer setting a parent of key to a value (not a map) hides it too.
+			if strings.HasPrefix(key, k+".") {
+				return Layer{}, false
+			}
+		}
+	}
+	return Layer{}, false
+}
+
+// installConfig replaces viper's config values with settings.
+// Viper will report file as the config file used.
+func installConfig(v *viper.Viper, file, configType string, settings map[string]interface{}) error {
+	v.SetConfigFile(file)
+	t := configType
+	if t == "" {
+		t = strings.TrimPrefix(filepath.Ext(file), ".")
+	}
+	// Empty the config, which needs something viper can parse as t.
+	empty := ""
+	if t == "json" {
+		empty = "{}"
+	}
+	if err := v.ReadConfig(strings.NewReader(empty)); err != nil {
+		return err
+	}
+	return v.MergeConfigMap(settings)
+}
+
+// mergeSettings deep merges src into dst, values in src replace those in dst.
+func mergeSettings(dst, src map[string]interface{}) {
+	for k, sv := range src {
+		if sm, ok := sv.(map[string]interface{}); ok {
+			if dm, ok := dst[k].(map[string]interface{}); ok {
+				mergeSettings(dm, sm)
+				continue
+			}
+			dm := make(map[string]interface{}, len(sm))
+			mergeSettings(dm, sm)
+			dst[k] = dm
+			continue
+		}
+		dst[k] = sv
+	}
+}
+
+func stringIn(s string, ss []string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
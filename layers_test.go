@@ -0,0 +1,86 @@
+package vconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestLayeredConfig(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	sys := writeConfig(t, dir, "etc/app.yaml", "screen: system\nserver:\n  host: sys-host\n  port: 1\n")
+	user := writeConfig(t, dir, "home/.app.toml", "[server]\nport = 2\n")
+	proj := writeConfig(t, dir, "project/app.yaml", "screen: project\n")
+	expl := writeConfig(t, dir, "explicit.json", `{"filename": "explicit"}`)
+
+	layers := []Layer{
+		{Name: SystemLayer, File: filepath.Join(dir, "etc/app")},
+		{Name: UserLayer, File: filepath.Join(dir, "home/.app")},
+		{Name: "missing", File: filepath.Join(dir, "nowhere/app")},
+		{Name: ProjectLayer, File: filepath.Join(dir, "project/app")},
+		{Name: ExplicitLayer, File: expl},
+	}
+
+	b := NewBinder(viper.New())
+	if err := b.Init(WithLayers(layers...), WithoutEnv()); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+
+	type tc struct {
+		key, e string
+		file   string
+	}
+	cases := []tc{
+		{key: "server.host", e: "sys-host", file: sys},
+		{key: "server.port", e: "2", file: user},
+		{key: "screen", e: "project", file: proj},
+		{key: "filename", e: "explicit", file: expl},
+	}
+	for _, c := range cases {
+		if v := b.Viper().GetString(c.key); v != c.e {
+			t.Errorf("Wrong merged value for %q. Got: %#v, Expected: %#v", c.key, v, c.e)
+		}
+		if l, ok := b.LayerFor(c.key); !ok || l.File != c.file {
+			t.Errorf("Wrong layer for %q. Got: %#v, Expected file: %#v", c.key, l, c.file)
+		}
+	}
+	if ls := b.Layers(); len(ls) != 4 || ls[3].Name != ExplicitLayer {
+		t.Errorf("Wrong layers read: %#v", ls)
+	}
+	if u := b.LastDiscovery().Used; u != expl {
+		t.Errorf("The explicit file should be reported as used. Got: %#v", u)
+	}
+
+	// Flags and Sets stay on top through a reload.
+	pflags := pflag.NewFlagSet("Layers", pflag.ContinueOnError)
+	pflags.String("screen", "", "")
+	b.Bind("screen", pflags.Lookup("screen"))
+	pflags.Parse([]string{"--screen", "flag"})
+	b.UpdateChangedFlags()
+	b.Apply()
+	b.Set("server.port", 99)
+
+	writeConfig(t, dir, "project/app.yaml", "screen: project-two\nconnection: reloaded\n")
+	if err := b.Reload(); err != nil {
+		t.Fatalf("Unexpected error from Reload: %v", err)
+	}
+	for k, e := range map[string]string{"screen": "flag", "server.port": "99", "connection": "reloaded"} {
+		if v := b.Viper().GetString(k); v != e {
+			t.Errorf("Wrong value for %q after reload. Got: %#v, Expected: %#v", k, v, e)
+		}
+	}
+
+	// A missing explicit file is an error.
+	b = NewBinder(viper.New())
+	err := b.Init(WithLayers(Layer{Name: ExplicitLayer, File: filepath.Join(dir, "nothere.yaml")}))
+	var nf *ConfigNotFoundError
+	if !errors.As(err, &nf) {
+		t.Errorf("Expected a ConfigNotFoundError for a missing explicit layer. Got: %#v", err)
+	}
+}
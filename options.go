@@ -0,0 +1,194 @@
+package vconfig
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// Option configures how Init finds and reads the config file and environment.
+type Option func(*options)
+
+type options struct {
+	appName     string
+	configFile  string   // A specific file, skips the search.
+	configName  string   // File name, without extension, to search for.
+	paths       []string // Where to search, in order.
+	configType  string   // Used for files without an extension.
+	xdg         bool     // Search the XDG Base Directory locations.
+	layered     bool     // Merge several files, see layers.go.
+	layers      []Layer  // The files to merge, if not the default.
+	env         bool
+	envPrefix   string
+	envReplacer *strings.Replacer
+	journal     bool   // Keep the journal in a file.
+	journalFile string // Where, if not next to the history file.
+	schema      *Schema
+	explicitEnv bool // Only read the environment for keys given to BindEnv.
+}
+
+// newOptions returns the options Init uses when none are given,
+// then applies opts.
+func newOptions(opts ...Option) *options {
+	o := &options{
+		appName: AppName,
+		env:     true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.configName == "" {
+		o.configName = o.appName
+	}
+	return o
+}
+
+// WithAppName sets the application name, which is also the
+// config file name if WithConfigName isn't used.
+func WithAppName(name string) Option {
+	return func(o *options) { o.appName = name }
+}
+
+// WithConfigFile reads the named file rather than searching for one.
+func WithConfigFile(path string) Option {
+	return func(o *options) { o.configFile = path }
+}
+
+// WithConfigName sets the file name, without an extension, to search for.
+func WithConfigName(name string) Option {
+	return func(o *options) { o.configName = name }
+}
+
+// WithSearchPaths sets the directories to search for the config file, in order.
+// A leading ~ is expanded to the home directory.
+// The default is the current directory and then the home directory.
+func WithSearchPaths(paths ...string) Option {
+	return func(o *options) { o.paths = append([]string(nil), paths...) }
+}
+
+// WithConfigType sets the format, e.g. "yaml", of config files without an extension.
+func WithConfigType(t string) Option {
+	return func(o *options) { o.configType = t }
+}
+
+// WithEnvPrefix only reads environment variables starting with prefix and an underscore,
+// e.g. MYAPP_DEBUG for the key debug and the prefix "myapp".
+func WithEnvPrefix(prefix string) Option {
+	return func(o *options) { o.envPrefix = prefix }
+}
+
+// WithEnvKeyReplacer sets how keys are turned into environment variable names.
+// The default makes dots and dashes into underscores, to read server.port from SERVER_PORT.
+func WithEnvKeyReplacer(r *strings.Replacer) Option {
+	return func(o *options) { o.envReplacer = r }
+}
+
+// WithoutEnv doesn't read values from the environment.
+func WithoutEnv() Option {
+	return func(o *options) { o.env = false }
+}
+
+// searchPaths returns the directories to search with ~ expanded.
+func (o *options) searchPaths() ([]string, error) {
+	if o.paths == nil {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, &HomeDirError{Err: err}
+		}
+		if o.xdg {
+			return xdgSearchPaths(o.appName, home), nil
+		}
+		return []string{".", home}, nil
+	}
+	paths := make([]string, len(o.paths))
+	for i, p := range o.paths {
+		ep, err := homedir.Expand(p)
+		if err != nil {
+			return nil, &HomeDirError{Err: err}
+		}
+		paths[i] = ep
+	}
+	return paths, nil
+}
+
+// Init reads in the config file and environment as configured by opts.
+// It returns the same errors as InitConfigE, but leaves the package
+// variables (AppName, ConfigFileName ...) alone, except that under
+// WithXDG an empty HistoryFile is set to the one in $XDG_STATE_HOME.
+func Init(opts ...Option) error {
+	if o := newOptions(opts...); o.xdg && HistoryFile == "" {
+		HistoryFile = o.historyFile()
+	}
+	return std.Init(opts...)
+}
+
+// Init reads in the config file and environment for this Binder's viper.
+// See the package level Init.
+func (b *Binder) Init(opts ...Option) error {
+	if b.Debug() {
+		pef()
+		defer pxf()
+	}
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Pick up debug and verbose from the config and environment.
+	defer b.refresh()
+
+	o := newOptions(opts...)
+	v := b.Viper()
+	b.opts = o
+	before := b.settings()
+
+	if o.configType != "" {
+		v.SetConfigType(o.configType)
+	}
+
+	if o.env {
+		if o.envPrefix != "" {
+			v.SetEnvPrefix(o.envPrefix)
+		}
+		v.SetEnvKeyReplacer(o.replacer())
+		if !o.explicitEnv {
+			v.AutomaticEnv() // read in environment variables that match
+		}
+	}
+
+	// Read in the config file(s).
+	err := b.loadChecked(false)
+	if err == nil && b.Debug() {
+		fmt.Println("Using config file:", v.ConfigFileUsed())
+	}
+	// The environment is read even if the config file can't be.
+	if envErr := b.loadEnv(); err == nil {
+		err = envErr
+	}
+	// A profile from the environment.
+	if pErr := b.loadProfile(); err == nil {
+		err = pErr
+	}
+	b.changedSettings(before, b.settings())
+	if iErr := b.interpolate(); err == nil {
+		err = iErr
+	}
+	return err
+}
+
+// envName is the environment variable viper reads for key.
+func (o *options) envName(key string) string {
+	if o.envPrefix != "" {
+		key = o.envPrefix + "_" + key
+	}
+	return strings.ToUpper(o.replacer().Replace(key))
+}
+
+// defaultEnvReplacer makes keys into names that can be set in a shell.
+var defaultEnvReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+func (o *options) replacer() *strings.Replacer {
+	if o.envReplacer != nil {
+		return o.envReplacer
+	}
+	return defaultEnvReplacer
+}
@@ -0,0 +1,97 @@
+package vconfig
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func writeConfig(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatalf("Can't make config dir: %v", err)
+	}
+	if err := ioutil.WriteFile(p, []byte(contents), 0644); err != nil {
+		t.Fatalf("Can't write config file: %v", err)
+	}
+	return p
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "vconfig")
+	if err != nil {
+		t.Fatalf("Can't make a temp dir: %v", err)
+	}
+	return dir
+}
+
+func TestInitOptions(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	writeConfig(t, dir, "first/myapp.yaml", "screen: first\n")
+	writeConfig(t, dir, "second/myapp.yaml", "screen: second\n")
+	writeConfig(t, dir, "second/other.json", `{"screen": "other"}`)
+	noExt := writeConfig(t, dir, "noext", "screen: noext\n")
+
+	os.Setenv("MYAPP_SERVER_PORT", "9090")
+	os.Setenv("VCONFIG_TEST_SCREEN", "from-env")
+	defer os.Unsetenv("MYAPP_SERVER_PORT")
+	defer os.Unsetenv("VCONFIG_TEST_SCREEN")
+
+	type tc struct {
+		name  string
+		opts  []Option
+		key   string
+		e     string
+		isErr func(error) bool
+	}
+	cases := []tc{
+		{name: "search paths in order", key: "screen", e: "first",
+			opts: []Option{WithAppName("myapp"), WithSearchPaths(dir+"/first", dir+"/second")}},
+		{name: "search paths reversed", key: "screen", e: "second",
+			opts: []Option{WithAppName("myapp"), WithSearchPaths(dir+"/second", dir+"/first")}},
+		{name: "config name", key: "screen", e: "other",
+			opts: []Option{WithAppName("myapp"), WithConfigName("other"), WithSearchPaths(dir+"/first", dir+"/second")}},
+		{name: "config type", key: "screen", e: "noext",
+			opts: []Option{WithConfigFile(noExt), WithConfigType("yaml")}},
+		{name: "env prefix and replacer", key: "server.port", e: "9090",
+			opts: []Option{WithAppName("myapp"), WithSearchPaths(dir + "/first"),
+				WithEnvPrefix("myapp"), WithEnvKeyReplacer(strings.NewReplacer(".", "_"))}},
+		{name: "without env", key: "test_screen", e: "",
+			opts: []Option{WithAppName("myapp"), WithSearchPaths(dir + "/first"), WithEnvPrefix("vconfig"), WithoutEnv()}},
+		{name: "with env", key: "test_screen", e: "from-env",
+			opts: []Option{WithAppName("myapp"), WithSearchPaths(dir + "/first"), WithEnvPrefix("vconfig")}},
+		{name: "not found", key: "screen", e: "",
+			opts:  []Option{WithAppName("nothere"), WithSearchPaths(dir + "/first")},
+			isErr: func(err error) bool { var e *ConfigNotFoundError; return errors.As(err, &e) }},
+	}
+
+	appName, root := AppName, ConfigFileRoot
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := NewBinder(viper.New())
+			err := b.Init(c.opts...)
+			if c.isErr != nil {
+				if !c.isErr(err) {
+					t.Errorf("Wrong error from Init. Got: %#v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Unexpected error from Init: %v", err)
+			}
+			if v := b.Viper().GetString(c.key); v != c.e {
+				t.Errorf("Wrong value for %q. Got: %#v, Expected: %#v", c.key, v, c.e)
+			}
+		})
+	}
+	if AppName != appName || ConfigFileRoot != root {
+		t.Errorf("Init changed package variables. AppName: %q, ConfigFileRoot: %q", AppName, ConfigFileRoot)
+	}
+}
@@ -0,0 +1,158 @@
+package vconfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+/*
+* Flags for one command.
+*
+* In a REPL each command line is parsed into a flag set, but a --verbose
+* on one command shouldn't stay on for the next. PushFlags puts the
+* changed flags on top of everything else, and PopFlags puts back exactly
+* what was there before: a key that had no value from a flag or Set
+* goes back to having none, rather than getting the flag's default.
+*
+* Sets and Applies made while the flags are pushed go underneath them,
+* and are what's left after the pop.
+ */
+
+// flagFrame holds the flag values from one PushFlags.
+type flagFrame struct {
+	keys   []string // Bind keys, sorted.
+	values map[string]frameValue
+}
+
+type frameValue struct {
+	flag    string
+	value   interface{}
+	prev    interface{} // What was in viper's override layer.
+	hadPrev bool
+}
+
+// WithFlagOverrides runs fn with the changed flags in pflags applied, then removes them.
+func WithFlagOverrides(pflags *pflag.FlagSet, fn func() error) error {
+	return std.WithFlagOverrides(pflags, fn)
+}
+
+// WithFlagOverrides runs fn with the changed flags in pflags applied, then removes them.
+// See the package level WithFlagOverrides.
+func (b *Binder) WithFlagOverrides(pflags *pflag.FlagSet, fn func() error) error {
+	if err := b.PushFlags(pflags); err != nil {
+		return err
+	}
+	defer b.PopFlags()
+	return fn()
+}
+
+// PushFlags applies the bound flags that changed in pflags on top of all other values,
+// until the matching PopFlags. Pushes nest.
+// If any of the flags can't be converted nothing is pushed.
+func PushFlags(pflags *pflag.FlagSet) error {
+	return std.PushFlags(pflags)
+}
+
+// PushFlags applies the bound flags that changed in pflags on top of all other values.
+// See the package level PushFlags.
+func (b *Binder) PushFlags(pflags *pflag.FlagSet) error {
+	if b.Debug() {
+		pef()
+		defer pxf()
+	}
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.refresh()
+
+	f := &flagFrame{values: make(map[string]frameValue)}
+	bfs, values, errs := b.flagSetValues(pflags)
+	if err := errs.err(); err != nil {
+		return err
+	}
+	for _, bf := range bfs {
+		if fc, ok := values[bf]; ok {
+			f.keys = append(f.keys, bf.BindKey)
+			f.values[bf.BindKey] = frameValue{flag: fc.flag.Name, value: fc.value}
+		}
+	}
+	sort.Strings(f.keys)
+
+	for _, k := range f.keys {
+		fv := f.values[k]
+		fv.prev, fv.hadPrev = b.overrides[strings.ToLower(k)]
+		f.values[k] = fv
+		if b.Debug() {
+			fmt.Printf("Pushing flag value for %#v: %#v\n", k, b.redact(k, fv.value))
+		}
+		b.setViper(k, fv.value, SourceFlag)
+	}
+	b.frames = append(b.frames, f)
+	return b.followProfile()
+}
+
+// PopFlags removes the flags from the last PushFlags, putting back the values from before it.
+// It does nothing if there's nothing pushed.
+func PopFlags() {
+	std.PopFlags()
+}
+
+// PopFlags removes the flags from the last PushFlags.
+// See the package level PopFlags.
+func (b *Binder) PopFlags() {
+	if b.Debug() {
+		pef()
+		defer pxf()
+	}
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.refresh()
+
+	if len(b.frames) == 0 {
+		return
+	}
+	f := b.frames[len(b.frames)-1]
+	b.frames = b.frames[:len(b.frames)-1]
+	for _, k := range f.keys {
+		fv := f.values[k]
+		if b.Debug() {
+			fmt.Printf("Popping flag value for %#v, back to: %#v\n", k, b.redact(k, fv.prev))
+		}
+		if fv.hadPrev {
+			b.setViper(k, fv.prev, b.sourceOf(k))
+		} else {
+			b.setViper(k, nil, SourceDefault)
+		}
+	}
+	b.tryFollowProfile()
+}
+
+// write gives viper a new override for key, unless pushed flags hide it,
+// in which case it's kept to put back when they're popped. b.mu must be held.
+func (b *Binder) write(key string, val interface{}, src Source) {
+	for _, f := range b.frames {
+		if fv, ok := f.values[key]; ok {
+			// The lowest frame puts back what was there before any of them.
+			fv.prev, fv.hadPrev = val, val != nil
+			f.values[key] = fv
+			return
+		}
+	}
+	b.setViper(key, val, src)
+}
+
+// framed returns the top frame with a value for key. b.mu must be held.
+func (b *Binder) framed(key string) (*flagFrame, frameValue) {
+	for i := len(b.frames) - 1; i >= 0; i-- {
+		for k, fv := range b.frames[i].values {
+			if strings.EqualFold(k, key) {
+				return b.frames[i], fv
+			}
+		}
+	}
+	return nil, frameValue{}
+}
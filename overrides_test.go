@@ -0,0 +1,132 @@
+package vconfig
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// commandFlags parses a command line the way a REPL would for each command.
+func commandFlags(t *testing.T, b *Binder, args ...string) *pflag.FlagSet {
+	pflags := pflag.NewFlagSet("Command", pflag.ContinueOnError)
+	pflags.Bool(VerboseKey, false, "")
+	pflags.String("screen", "default-screen", "")
+	pflags.Int("count", 0, "")
+	for _, n := range []string{VerboseKey, "screen", "count"} {
+		b.Bind(n, pflags.Lookup(n))
+	}
+	if err := pflags.Parse(args); err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+	return pflags
+}
+
+func TestPushPopFlags(t *testing.T) {
+	b := NewBinder(viper.New())
+	v := b.Viper()
+	b.Set(VerboseKey, true)
+
+	type tc struct {
+		key           string
+		during, after interface{}
+		afterSet      bool
+	}
+	cases := []tc{
+		{key: VerboseKey, during: false, after: true, afterSet: true},
+		{key: "screen", during: "command", after: nil, afterSet: false},
+		{key: "count", during: 3, after: nil, afterSet: false},
+	}
+
+	pflags := commandFlags(t, b, "--verbose=false", "--screen", "command", "--count", "3")
+	if err := b.PushFlags(pflags); err != nil {
+		t.Fatalf("Unexpected error from PushFlags: %v", err)
+	}
+	for _, c := range cases {
+		if g := v.Get(c.key); g != c.during {
+			t.Errorf("Wrong value for %q during command. Got: %#v, Expected: %#v", c.key, g, c.during)
+		}
+		if e := b.Explain(c.key); e.Source() != SourceFlag {
+			t.Errorf("Wrong source for %q during command. Got: %s, Expected: %s", c.key, e.Source(), SourceFlag)
+		}
+	}
+	if b.Verbose() {
+		t.Errorf("Verbose should be off during the command.")
+	}
+	b.PopFlags()
+	for _, c := range cases {
+		if g := v.Get(c.key); g != c.after {
+			t.Errorf("Wrong value for %q after command. Got: %#v, Expected: %#v", c.key, g, c.after)
+		}
+		if s := v.IsSet(c.key); s != c.afterSet {
+			t.Errorf("Wrong IsSet for %q after command. Got: %#v, Expected: %#v", c.key, s, c.afterSet)
+		}
+	}
+	if !b.Verbose() {
+		t.Errorf("Verbose should be back on after the command.")
+	}
+	b.PopFlags() // Nothing to pop is fine.
+}
+
+func TestPushFlagsNested(t *testing.T) {
+	b := NewBinder(viper.New())
+	v := b.Viper()
+
+	b.PushFlags(commandFlags(t, b, "--screen", "outer"))
+	b.PushFlags(commandFlags(t, b, "--screen", "inner"))
+	if g := v.GetString("screen"); g != "inner" {
+		t.Errorf("Wrong value for inner command. Got: %#v, Expected: %#v", g, "inner")
+	}
+
+	// A Set during a command goes underneath the flags and is left after them.
+	b.Set("screen", "set")
+	if g := v.GetString("screen"); g != "inner" {
+		t.Errorf("Set should not hide the flag. Got: %#v, Expected: %#v", g, "inner")
+	}
+	b.PopFlags()
+	if g := v.GetString("screen"); g != "outer" {
+		t.Errorf("Wrong value after inner command. Got: %#v, Expected: %#v", g, "outer")
+	}
+	b.PopFlags()
+	if g := v.GetString("screen"); g != "set" {
+		t.Errorf("Wrong value after outer command. Got: %#v, Expected: %#v", g, "set")
+	}
+}
+
+func TestPushFlagsAfterReset(t *testing.T) {
+	reset()
+	defer reset()
+	Set("screen", "stale")
+	reset()
+
+	PushFlags(commandFlags(t, std, "--screen", "command"))
+	PopFlags()
+	if g := viper.Get("screen"); g != nil {
+		t.Errorf("Value from before the reset put back. Got: %#v", g)
+	}
+	if e := std.Explain("screen"); len(e.Candidates) != 0 {
+		t.Errorf("Sources from before the reset. Got: %#v", e)
+	}
+}
+
+func TestWithFlagOverrides(t *testing.T) {
+	b := NewBinder(viper.New())
+	v := b.Viper()
+	errCommand := errors.New("command failed")
+
+	var during interface{}
+	err := b.WithFlagOverrides(commandFlags(t, b, "--count", "7"), func() error {
+		during = v.Get("count")
+		return errCommand
+	})
+	if err != errCommand {
+		t.Errorf("Wrong error. Got: %#v, Expected: %#v", err, errCommand)
+	}
+	if during != 7 {
+		t.Errorf("Wrong value during command. Got: %#v, Expected: %#v", during, 7)
+	}
+	if v.IsSet("count") {
+		t.Errorf("count should be unset after the command, got: %#v", v.Get("count"))
+	}
+}
@@ -0,0 +1,283 @@
+package vconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+/*
+* Profiles.
+*
+* A profile is a named set of values laid over the config files, e.g. for
+* dev, staging and prod. They come from a profiles section in the files:
+*
+*	server:
+*	  host: localhost
+*	profiles:
+*	  prod:
+*	    server:
+*	      host: prod.example.com
+*
+* and from files next to each config file read with the profile name before
+* the extension, app.prod.yaml next to app.yaml, which go over the section.
+* Both show up in Layers, with the name ProfileLayer.
+*
+* The profile used is the value of the profile key, so it can come from the
+* config file, the environment, a --profile flag bound to ProfileKey or
+* UseProfile. When it changes the files are read again for the new profile.
+* Sets and flags are in viper's override layer, so they stay on top,
+* and there's a ChangeEvent for each key whose value changed.
+ */
+
+// ProfileLayer is the name of the layers that come from a profile.
+const ProfileLayer = "profile"
+
+// UseProfile switches to the profile name, or back to no profile if name is empty.
+// It's a Set of ProfileKey, so it can be undone.
+func UseProfile(name string) error {
+	return std.UseProfile(name)
+}
+
+// UseProfile switches this Binder to the profile name.
+// See the package level UseProfile.
+func (b *Binder) UseProfile(name string) error {
+	if b.Debug() {
+		pef()
+		defer pxf()
+	}
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.refresh()
+	var v interface{}
+	if name != "" {
+		v = name
+	}
+	if err := b.record(ProfileKey, v); err != nil {
+		return err
+	}
+	return b.followProfile()
+}
+
+// Profile returns the name of the profile in use, empty if there isn't one.
+func Profile() string {
+	return std.Profile()
+}
+
+// Profile returns the name of the profile this Binder is using.
+func (b *Binder) Profile() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.profile
+}
+
+// Profiles returns the names of the profiles found in the config files read, sorted.
+func Profiles() []string {
+	return std.Profiles()
+}
+
+// Profiles returns the names of the profiles found in this Binder's config files.
+func (b *Binder) Profiles() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.profileNames...)
+}
+
+// followProfile reads the config files again if the profile has changed since they were read.
+// b.mu must be held.
+// Each key whose value changes gets a ChangeEvent.
+func (b *Binder) followProfile() error {
+	if !b.profileChanged() {
+		return nil
+	}
+	before := b.settings()
+	err := b.loadProfile()
+	b.changedSettings(before, b.settings())
+	return err
+}
+
+// loadProfile reads the config files again if the profile has changed, without reporting
+// the changes, for callers that report on their own. b.mu must be held.
+func (b *Binder) loadProfile() error {
+	if !b.profileChanged() {
+		return nil
+	}
+	if b.Debug() {
+		fmt.Printf("Switching profile from %#v to %#v\n", b.profile, b.Viper().Get(ProfileKey))
+	}
+	if err := b.loadChecked(true); err != nil {
+		return err
+	}
+	return b.loadEnv()
+}
+
+func (b *Binder) profileChanged() bool {
+	return b.opts != nil && cast.ToString(b.Viper().Get(ProfileKey)) != b.profile
+}
+
+// tryFollowProfile is followProfile for callers that can't return the error.
+// b.mu must be held.
+func (b *Binder) tryFollowProfile() {
+	if err := b.followProfile(); err != nil && b.Debug() {
+		fmt.Printf("Switching profile: %v\n", err)
+	}
+}
+
+// profileName works out the profile from what will be in viper once settings are installed.
+// b.mu must be held.
+func (b *Binder) profileName(settings map[string]interface{}) string {
+	if v, ok := b.overrides[ProfileKey]; ok {
+		return cast.ToString(v)
+	}
+	if v, ok := b.envValues[ProfileKey]; ok {
+		return cast.ToString(v)
+	}
+	if o := b.opts; o != nil && o.env && !o.explicitEnv {
+		if v, ok := os.LookupEnv(o.envName(ProfileKey)); ok {
+			return v
+		}
+	}
+	if v, ok := settings[ProfileKey]; ok {
+		return cast.ToString(v)
+	}
+	return cast.ToString(b.defaults[ProfileKey])
+}
+
+// install gives viper the settings from the config files in layers, with the profile
+// laid over them. file is the one viper reports as used. b.mu must be held.
+func (b *Binder) install(file string, settings map[string]interface{}, layers []loadedLayer) error {
+	name := b.profileName(settings)
+	var names []string
+	if ps, ok := settings[ProfilesKey].(map[string]interface{}); ok {
+		for n := range ps {
+			names = append(names, n)
+		}
+	}
+
+	var overlays []loadedLayer
+	var looked []string
+	if name != "" {
+		// The section, from the highest layer that has it.
+		if ps, ok := lookupSetting(settings, ProfilesKey+"."+strings.ToLower(name)); ok {
+			if pm, ok := ps.(map[string]interface{}); ok {
+				pl := loadedLayer{Layer: Layer{Name: ProfileLayer, File: file}, keys: settingKeys(pm, ""), settings: pm}
+				for _, l := range layers {
+					if _, ok := lookupSetting(l.settings, ProfilesKey+"."+strings.ToLower(name)); ok {
+						pl.File = l.File
+					}
+				}
+				overlays = append(overlays, pl)
+			}
+		}
+		// Then the files, next to those that aren't included.
+		for _, l := range layers {
+			if l.Name == IncludeLayer {
+				continue
+			}
+			pf := profileFile(l.File, name)
+			looked = append(looked, pf)
+			if !isFile(pf) {
+				continue
+			}
+			lv := viper.New()
+			lv.SetConfigFile(pf)
+			if b.opts.configType != "" && filepath.Ext(pf) == "" {
+				lv.SetConfigType(b.opts.configType)
+			}
+			if err := readInConfig(lv, b.opts.configName, nil); err != nil {
+				return err
+			}
+			overlays = append(overlays, loadedLayer{Layer: Layer{Name: ProfileLayer, File: pf}, keys: lv.AllKeys(), settings: lv.AllSettings()})
+		}
+	}
+	for _, l := range layers {
+		if l.Name != IncludeLayer {
+			names = append(names, profileFiles(l.File)...)
+		}
+	}
+
+	if len(overlays) > 0 {
+		merged := make(map[string]interface{})
+		mergeSettings(merged, settings)
+		for i, ol := range overlays {
+			// A profile can't choose the profile.
+			ps := make(map[string]interface{})
+			mergeSettings(ps, ol.settings)
+			delete(ps, ProfileKey)
+			overlays[i].settings = ps
+			var keys []string
+			for _, k := range ol.keys {
+				if k != ProfileKey {
+					keys = append(keys, k)
+				}
+			}
+			overlays[i].keys = keys
+			mergeSettings(merged, ps)
+		}
+		settings = merged
+	}
+	if err := installConfig(b.Viper(), file, b.opts.configType, settings); err != nil {
+		return err
+	}
+	b.layers = append(layers, overlays...)
+	b.profile, b.profileFiles = name, looked
+	b.profileNames = uniqueSorted(names)
+	return nil
+}
+
+// profileFile is the file for profile next to a config file: app.prod.yaml for app.yaml.
+func profileFile(file, profile string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + profile + ext
+}
+
+// profileFiles returns the names of the profiles with files next to file.
+func profileFiles(file string) (names []string) {
+	ext := filepath.Ext(file)
+	stem := strings.TrimSuffix(file, ext)
+	matches, _ := filepath.Glob(globEscape(stem) + ".*" + globEscape(ext))
+	for _, m := range matches {
+		n := strings.TrimSuffix(strings.TrimPrefix(m, stem+"."), ext)
+		if n != "" && !strings.Contains(n, ".") {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+func globEscape(s string) string {
+	r := strings.NewReplacer(`*`, `\*`, `?`, `\?`, `[`, `\[`, `\`, `\\`)
+	return r.Replace(s)
+}
+
+// settingKeys returns the keys, flattened with dots, of the values in nested settings.
+func settingKeys(settings map[string]interface{}, prefix string) (keys []string) {
+	for k, v := range settings {
+		if m, ok := v.(map[string]interface{}); ok {
+			keys = append(keys, settingKeys(m, joinKey(prefix, k))...)
+			continue
+		}
+		keys = append(keys, joinKey(prefix, k))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func uniqueSorted(ss []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
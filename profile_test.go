@@ -0,0 +1,196 @@
+package vconfig
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestProfiles(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := writeConfig(t, dir, "app.yaml", `screen: file
+server:
+  host: localhost
+  port: 80
+profiles:
+  staging:
+    server:
+      host: staging.example.com
+  prod:
+    server:
+      host: prod.example.com
+      port: 443
+`)
+	prodFile := writeConfig(t, dir, "app.prod.yaml", "screen: prod-file\nprofile: staging\n")
+	writeConfig(t, dir, "app.test.yaml", "screen: test-file\n")
+
+	b := NewBinder(viper.New())
+	v := b.Viper()
+	if err := b.Init(WithConfigFile(file), WithoutEnv()); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+	if p := b.Profile(); p != "" {
+		t.Errorf("Wrong profile with none chosen. Got: %#v, Expected: %#v", p, "")
+	}
+	if ps, e := b.Profiles(), []string{"prod", "staging", "test"}; !reflect.DeepEqual(ps, e) {
+		t.Errorf("Wrong profiles. Got: %#v, Expected: %#v", ps, e)
+	}
+
+	var events []ChangeEvent
+	b.OnAnyChange(func(ev ChangeEvent) { events = append(events, ev) })
+	b.Set("server.port", 8080)
+
+	type tc struct {
+		name    string
+		profile string
+		values  map[string]interface{}
+		layer   Layer // For server.host.
+	}
+	cases := []tc{
+		{
+			name:    "section",
+			profile: "staging",
+			values:  map[string]interface{}{"server.host": "staging.example.com", "server.port": 8080, "screen": "file"},
+			layer:   Layer{Name: ProfileLayer, File: file},
+		},
+		{
+			name:    "section and file",
+			profile: "prod",
+			values:  map[string]interface{}{"server.host": "prod.example.com", "server.port": 8080, "screen": "prod-file", ProfileKey: "prod"},
+			layer:   Layer{Name: ProfileLayer, File: file},
+		},
+		{
+			name:    "file only",
+			profile: "test",
+			values:  map[string]interface{}{"server.host": "localhost", "server.port": 8080, "screen": "test-file"},
+			layer:   Layer{Name: ConfigLayer, File: file},
+		},
+		{
+			name:    "none",
+			profile: "",
+			values:  map[string]interface{}{"server.host": "localhost", "server.port": 8080, "screen": "file"},
+			layer:   Layer{Name: ConfigLayer, File: file},
+		},
+	}
+	for _, c := range cases {
+		if err := b.UseProfile(c.profile); err != nil {
+			t.Fatalf("%s: Unexpected error from UseProfile: %v", c.name, err)
+		}
+		if p := b.Profile(); p != c.profile {
+			t.Errorf("%s: Wrong profile. Got: %#v, Expected: %#v", c.name, p, c.profile)
+		}
+		for k, e := range c.values {
+			if g := v.Get(k); !reflect.DeepEqual(g, e) {
+				t.Errorf("%s: Wrong value for %q. Got: %#v, Expected: %#v", c.name, k, g, e)
+			}
+		}
+		if l, _ := b.LayerFor("server.host"); l != c.layer {
+			t.Errorf("%s: Wrong layer for server.host. Got: %#v, Expected: %#v", c.name, l, c.layer)
+		}
+	}
+	if ls := b.Layers(); len(ls) != 1 {
+		t.Errorf("Profile layers left after switching back. Got: %#v", ls)
+	}
+
+	// The profile file shows in Explain.
+	b.UseProfile("prod")
+	if ex := b.Explain("screen"); ex.Source() != SourceFile || ex.Candidates[ex.Winner].Origin != ProfileLayer+": "+prodFile {
+		t.Errorf("Wrong explanation for screen in the prod profile. Got: %#v, Expected file: %#v", ex, prodFile)
+	}
+	b.UseProfile("")
+
+	// Each switch reports the keys that changed.
+	type ec struct {
+		key      string
+		old, new interface{}
+	}
+	expected := []ec{
+		{key: "server.port", old: 80, new: 8080},
+		{key: ProfileKey, old: nil, new: "staging"},
+		{key: "server.host", old: "localhost", new: "staging.example.com"},
+		{key: ProfileKey, old: "staging", new: "prod"},
+		{key: "screen", old: "file", new: "prod-file"},
+		{key: "server.host", old: "staging.example.com", new: "prod.example.com"},
+	}
+	if len(events) < len(expected) {
+		t.Fatalf("Too few events. Got: %#v, Expected at least: %#v", events, expected)
+	}
+	for i, e := range expected {
+		ev := events[i]
+		if ev.Key != e.key || !reflect.DeepEqual(ev.Old, e.old) || !reflect.DeepEqual(ev.New, e.new) {
+			t.Errorf("Wrong event %d. Got: %#v, Expected: %#v", i, ev, e)
+		}
+	}
+
+	// Switching can be undone.
+	b.UseProfile("staging")
+	if _, err := b.Undo(); err != nil {
+		t.Fatalf("Unexpected error from Undo: %v", err)
+	}
+	if p, h := b.Profile(), v.GetString("server.host"); p != "" || h != "localhost" {
+		t.Errorf("Wrong profile after Undo. Got: %#v with host %#v, Expected: %#v with host %#v", p, h, "", "localhost")
+	}
+}
+
+func TestProfileSources(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := writeConfig(t, dir, "app.yaml", `profile: staging
+server:
+  host: localhost
+profiles:
+  staging:
+    server:
+      host: staging.example.com
+  prod:
+    server:
+      host: prod.example.com
+`)
+
+	// From the config file.
+	b := NewBinder(viper.New())
+	if err := b.Init(WithConfigFile(file), WithoutEnv()); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+	if p, h := b.Profile(), b.Viper().GetString("server.host"); p != "staging" || h != "staging.example.com" {
+		t.Errorf("Wrong profile from the config file. Got: %#v with host %#v", p, h)
+	}
+
+	// From the environment, over the file.
+	defer setEnv(t, map[string]string{"PROFTEST_PROFILE": "prod"})()
+	b = NewBinder(viper.New())
+	if err := b.Init(WithConfigFile(file), WithEnvPrefix("PROFTEST")); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+	if p, h := b.Profile(), b.Viper().GetString("server.host"); p != "prod" || h != "prod.example.com" {
+		t.Errorf("Wrong profile from the environment. Got: %#v with host %#v", p, h)
+	}
+
+	// From a flag, over the environment, and back when the flag goes.
+	pflags := pflag.NewFlagSet("Profiles", pflag.ContinueOnError)
+	pflags.String("profile", "", "")
+	b.Bind(ProfileKey, pflags.Lookup("profile"))
+	pflags.Parse([]string{"--profile", "staging"})
+	if err := b.ApplyFromFlags(pflags); err != nil {
+		t.Fatalf("Unexpected error from ApplyFromFlags: %v", err)
+	}
+	if p, h := b.Profile(), b.Viper().GetString("server.host"); p != "staging" || h != "staging.example.com" {
+		t.Errorf("Wrong profile from the flag. Got: %#v with host %#v", p, h)
+	}
+	b.Apply()
+	if p, h := b.Profile(), b.Viper().GetString("server.host"); p != "prod" || h != "prod.example.com" {
+		t.Errorf("Wrong profile after the flag was taken away. Got: %#v with host %#v", p, h)
+	}
+
+	// A profile that doesn't exist just has no values of its own.
+	if err := b.UseProfile("nowhere"); err != nil {
+		t.Fatalf("Unexpected error from UseProfile: %v", err)
+	}
+	if p, h := b.Profile(), b.Viper().GetString("server.host"); p != "nowhere" || h != "localhost" {
+		t.Errorf("Wrong profile for a missing one. Got: %#v with host %#v", p, h)
+	}
+}
@@ -0,0 +1,296 @@
+package vconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/*
+* Saving changes.
+*
+* Save writes the values given to bound keys with Set (not those from
+* flags, the environment or the other config files) back into the config
+* file. Sets of keys that aren't bound, and of the keys for the session,
+* debug, verbose and the profile, aren't saved. Rather than
+* writing the whole config out again we edit the file, changing only the
+* lines for the keys that changed, so comments, the order of the keys and
+* the format are kept. See saveedit.go for the editing.
+*
+* The file is written to a temporary file in the same directory, which
+* is then renamed over the original, so it is never half written.
+ */
+
+// Save writes the values of bound keys changed with Set to the config file that was read.
+// With layered config files that's the one with the highest precedence.
+func Save() error {
+	return std.Save()
+}
+
+// Save writes the values changed with Set to the config file that was read.
+// See the package level Save.
+func (b *Binder) Save() error {
+	return b.SaveAs("")
+}
+
+// SaveAs writes the config file that was read, with the values changed with Set, to path.
+// If path already exists the values are written into it instead.
+// An empty path is the config file that was read.
+func SaveAs(path string) error {
+	return std.SaveAs(path)
+}
+
+// SaveAs writes the values changed with Set to path.
+// See the package level SaveAs.
+func (b *Binder) SaveAs(path string) error {
+	if b.Debug() {
+		pef()
+		defer pxf()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	path, old, updated, err := b.saveContents(path)
+	if err != nil {
+		return err
+	}
+	if string(old) == string(updated) && isFile(path) {
+		return nil
+	}
+	return writeAtomic(path, updated)
+}
+
+// SaveDiff returns the changes that SaveAs(path) would make to the file,
+// as a unified diff, without writing anything.
+func SaveDiff(path string) (string, error) {
+	return std.SaveDiff(path)
+}
+
+// SaveDiff returns the changes that SaveAs(path) would make to the file.
+// See the package level SaveDiff.
+func (b *Binder) SaveDiff(path string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	path, old, updated, err := b.saveContents(path)
+	if err != nil {
+		return "", err
+	}
+	format := configFormat(path, b.opts)
+	return unifiedDiffShown(path, string(old), string(updated), b.redactText(old, format), b.redactText(updated, format)), nil
+}
+
+// redactText returns the text of a config file in format, with the values
+// of secret keys Redacted, line for line. b.mu must be held.
+func (b *Binder) redactText(data []byte, format string) string {
+	lines := strings.Split(string(data), "\n")
+	settings, err := readSettings(data, format)
+	if err != nil {
+		return string(data)
+	}
+	for _, k := range settingKeys(settings, "") {
+		if !b.isSecretIn(k) {
+			continue
+		}
+		if n := keyLineIn(lines, k, format); n > 0 {
+			lines[n-1] = redactLine(lines[n-1], format)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// redactLine replaces the value on a line of a config file in format with Redacted.
+func redactLine(l, format string) string {
+	var i int
+	switch format {
+	case "toml":
+		i = strings.Index(l, "=")
+	case "json":
+		// The colon after the quoted key.
+		if i = strings.Index(l, `":`); i >= 0 {
+			i++
+		}
+	default:
+		i = strings.Index(l, ":")
+	}
+	if i < 0 {
+		return l
+	}
+	rest := l[i+1:]
+	if format == "json" {
+		end := ""
+		if strings.HasSuffix(strings.TrimSpace(rest), ",") {
+			end = ","
+		}
+		return l[:i+1] + ` "` + Redacted + `"` + end
+	}
+	_, comment := splitComment(rest)
+	return l[:i+1] + " " + Redacted + comment
+}
+
+// saveContents returns the file to save to, what's in it now
+// and what to write to it. b.mu must be held.
+func (b *Binder) saveContents(path string) (string, []byte, []byte, error) {
+	used := b.Viper().ConfigFileUsed()
+	if path == "" {
+		path = used
+	}
+	if path == "" {
+		return "", nil, nil, fmt.Errorf("no config file to save to")
+	}
+
+	// Start with the file if it's there, or the file read if it's the same format.
+	format := configFormat(path, b.opts)
+	var old, src []byte
+	var err error
+	if isFile(path) {
+		if old, err = ioutil.ReadFile(path); err != nil {
+			return path, nil, nil, err
+		}
+		src = old
+	} else if used != "" && configFormat(used, b.opts) == format {
+		if src, err = ioutil.ReadFile(used); err != nil {
+			return path, nil, nil, err
+		}
+	}
+
+	edit, ok := configEditors[format]
+	if !ok {
+		return path, nil, nil, fmt.Errorf("can't save config format %q to %s", format, path)
+	}
+	current, err := readSettings(src, format)
+	if err != nil {
+		return path, nil, nil, &ConfigParseError{File: path, Err: err}
+	}
+
+	updated := src
+	changes := b.changedValues()
+	for _, k := range sortedKeys(changes) {
+		v := changes[k]
+		if cv, ok := lookupSetting(current, k); ok && sameValue(cv, v) {
+			continue
+		}
+		if b.Debug() {
+			fmt.Printf("Saving %#v as %#v to %s\n", k, b.redact(k, v), path)
+		}
+		if updated, err = edit(updated, k, v); err != nil {
+			return path, nil, nil, fmt.Errorf("saving %q to %s: %w", k, path, err)
+		}
+	}
+	return path, old, updated, nil
+}
+
+// sessionKeys are for the session, and not saved.
+var sessionKeys = []string{DebugKey, VerboseKey, ProfileKey}
+
+// changedValues returns the values from Set for bound keys, ready to write out. b.mu must be held.
+func (b *Binder) changedValues() map[string]interface{} {
+	vals := make(map[string]interface{})
+	for _, bf := range b.bbm {
+		if bf.value != nil && bf.source == SourceSet && !isSessionKey(bf.BindKey) {
+			vals[strings.ToLower(bf.BindKey)] = fileValue(bf.value, bf.Flag.Value.Type())
+		}
+	}
+	return vals
+}
+
+func isSessionKey(k string) bool {
+	for _, sk := range sessionKeys {
+		if strings.EqualFold(sk, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileValue turns v into something the config file formats can hold.
+// Values of types with a codec are written as the codec encodes them.
+func fileValue(v interface{}, typ string) interface{} {
+	if c, ok := codecFor(typ); ok {
+		if s, err := c.Encode(v); err == nil {
+			return s
+		}
+	}
+	switch tv := v.(type) {
+	case nil, bool, string, int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case fmt.Stringer:
+		// e.g. time.Duration and net.IP, which read back from their strings.
+		return tv.String()
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		l := make([]interface{}, rv.Len())
+		for i := range l {
+			l[i] = fileValue(rv.Index(i).Interface(), "")
+		}
+		return l
+	case reflect.Map:
+		m := make(map[string]interface{}, rv.Len())
+		for _, mk := range rv.MapKeys() {
+			m[fmt.Sprint(mk.Interface())] = fileValue(rv.MapIndex(mk).Interface(), "")
+		}
+		return m
+	}
+	return fmt.Sprint(v)
+}
+
+// sameValue reports whether a value read from a file and one to write are the same.
+func sameValue(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b) || fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// configFormat is the format of the config file path.
+func configFormat(path string, o *options) string {
+	if ext := strings.TrimPrefix(filepath.Ext(path), "."); ext != "" {
+		return strings.ToLower(ext)
+	}
+	if o != nil {
+		return o.configType
+	}
+	return ""
+}
+
+// writeAtomic replaces the file at path with data, keeping its permissions.
+func writeAtomic(path string, data []byte) (err error) {
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Chmod(mode); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,192 @@
+package vconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestConfigEditors(t *testing.T) {
+	type tc struct {
+		format   string
+		src      string
+		key      string
+		value    interface{}
+		expected string
+	}
+	cases := []tc{
+		// YAML
+		{"yaml", "# Top\nscreen: light # the screen\nverbose: false\n", "screen", "darkScreen",
+			"# Top\nscreen: darkScreen # the screen\nverbose: false\n"},
+		{"yaml", "server:\n    host: a\n    port: 1 # port\nother: x\n", "server.port", 2,
+			"server:\n    host: a\n    port: 2 # port\nother: x\n"},
+		{"yaml", "server:\n  host: a\n\nother: x\n", "server.port", 2,
+			"server:\n  host: a\n  port: 2\n\nother: x\n"},
+		{"yaml", "screen: a\n", "server.port", 2, "screen: a\nserver:\n  port: 2\n"},
+		{"yaml", "list:\n- a\n- b\nnext: 1\n", "list", []interface{}{"c"}, "list:\n  - c\nnext: 1\n"},
+		{"yaml", "", "screen", "dark", "screen: dark\n"},
+		// TOML
+		{"toml", "# Top\nscreen = \"light\" # the screen\n\n[server]\nport = 1\n", "screen", "dark",
+			"# Top\nscreen = \"dark\" # the screen\n\n[server]\nport = 1\n"},
+		{"toml", "screen = \"light\"\n\n[server]\nport = 1\n", "server.port", 2,
+			"screen = \"light\"\n\n[server]\nport = 2\n"},
+		{"toml", "[server]\nport = 1\n\n[other]\na = 1\n", "server.host", "h",
+			"[server]\nport = 1\nhost = \"h\"\n\n[other]\na = 1\n"},
+		{"toml", "[server]\nport = 1\n", "verbose", true, "verbose = true\n\n[server]\nport = 1\n"},
+		{"toml", "screen = \"a\"\n", "tls.certs", []interface{}{"x", "y"}, "screen = \"a\"\n\n[tls]\ncerts = [\"x\", \"y\"]\n"},
+		{"toml", "ratio = 1.5\n", "ratio", 2.0, "ratio = 2.0\n"},
+		// JSON
+		{"json", "{\n  \"screen\": \"light\",\n  \"server\": {\n    \"port\": 1\n  }\n}\n", "server.port", 2,
+			"{\n  \"screen\": \"light\",\n  \"server\": {\n    \"port\": 2\n  }\n}\n"},
+		{"json", "{\n  \"screen\": \"light\"\n}\n", "verbose", true,
+			"{\n  \"screen\": \"light\",\n  \"verbose\": true\n}\n"},
+		{"json", "{\"screen\": \"light\"}", "server.port", 2, "{\"screen\": \"light\", \"server\": {\"port\":2}}"},
+		{"json", "", "screen", "dark", "{\n  \"screen\": \"dark\"\n}\n"},
+	}
+	for i, c := range cases {
+		out, err := configEditors[c.format]([]byte(c.src), c.key, c.value)
+		if err != nil {
+			t.Errorf("Unexpected error for case %d: %v", i, err)
+			continue
+		}
+		if string(out) != c.expected {
+			t.Errorf("Wrong edit for case %d (%s %s).\nGot:\n%s\nExpected:\n%s", i, c.format, c.key, out, c.expected)
+		}
+	}
+
+	// Layouts we don't edit are errors, not broken files.
+	errCases := []tc{
+		{format: "yaml", src: "server: {port: 1}\n", key: "server.port", value: 2},
+		{format: "toml", src: "list = [\n  1,\n]\n", key: "list", value: []interface{}{2}},
+		{format: "json", src: "{\"server\": 1}", key: "server.port", value: 2},
+	}
+	for i, c := range errCases {
+		if _, err := configEditors[c.format]([]byte(c.src), c.key, c.value); err == nil {
+			t.Errorf("Expected an error for case %d: %#v", i, c)
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	contents := "# Settings\nscreen: light # the screen\ntimeout: 1s\nverbose: false\n"
+	file := writeConfig(t, dir, "app.yaml", contents)
+
+	b := NewBinder(viper.New())
+	if err := b.Init(WithConfigFile(file), WithoutEnv()); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+	pflags := pflag.NewFlagSet("Save", pflag.ContinueOnError)
+	pflags.String("screen", "", "")
+	pflags.Duration("timeout", 0, "")
+	pflags.Bool(VerboseKey, false, "")
+	pflags.Int("port", 0, "")
+	for _, n := range []string{"screen", "timeout", VerboseKey} {
+		b.Bind(n, pflags.Lookup(n))
+	}
+	b.Bind("server.port", pflags.Lookup("port"))
+	b.Set("screen", "darkScreen")
+	b.Set("timeout", 2*time.Minute)
+	b.Set("server.port", 8080)
+	// Keys that aren't bound, and those for the session, aren't saved.
+	b.Set("unbound", "x")
+	b.Toggle(DebugKey)
+	b.UseProfile("prod")
+	// Flags aren't saved.
+	pflags.Parse([]string{"--verbose"})
+	b.ApplyFromFlags(pflags)
+
+	expected := "# Settings\nscreen: darkScreen # the screen\ntimeout: 2m0s\nverbose: false\nserver:\n  port: 8080\n"
+	diff, err := b.SaveDiff("")
+	if err != nil {
+		t.Fatalf("Unexpected error from SaveDiff: %v", err)
+	}
+	for _, l := range []string{"-screen: light # the screen", "+screen: darkScreen # the screen", "+  port: 8080", " verbose: false"} {
+		if !strings.Contains(diff, l+"\n") {
+			t.Errorf("Diff is missing %q:\n%s", l, diff)
+		}
+	}
+	if got, _ := ioutil.ReadFile(file); string(got) != contents {
+		t.Errorf("SaveDiff changed the file:\n%s", got)
+	}
+
+	if err := b.Save(); err != nil {
+		t.Fatalf("Unexpected error from Save: %v", err)
+	}
+	if got, _ := ioutil.ReadFile(file); string(got) != expected {
+		t.Errorf("Wrong file after Save.\nGot:\n%s\nExpected:\n%s", got, expected)
+	}
+	if diff, _ := b.SaveDiff(""); diff != "" {
+		t.Errorf("Nothing should be left to save:\n%s", diff)
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(dir, ".app.yaml.tmp*")); len(tmps) != 0 {
+		t.Errorf("Temporary files left behind: %q", tmps)
+	}
+
+	// SaveAs to a new file starts with the one read.
+	other := filepath.Join(dir, "other.yaml")
+	if err := b.SaveAs(other); err != nil {
+		t.Fatalf("Unexpected error from SaveAs: %v", err)
+	}
+	if got, _ := ioutil.ReadFile(other); string(got) != expected {
+		t.Errorf("Wrong file after SaveAs.\nGot:\n%s\nExpected:\n%s", got, expected)
+	}
+	// In another format it only has the changes.
+	js := filepath.Join(dir, "other.json")
+	if err := b.SaveAs(js); err != nil {
+		t.Fatalf("Unexpected error from SaveAs: %v", err)
+	}
+	ejs := "{\n  \"screen\": \"darkScreen\",\n  \"server\": {\n    \"port\": 8080\n  },\n  \"timeout\": \"2m0s\"\n}\n"
+	if got, _ := ioutil.ReadFile(js); string(got) != ejs {
+		t.Errorf("Wrong json file after SaveAs.\nGot:\n%s\nExpected:\n%s", got, ejs)
+	}
+}
+
+func TestSaveDiffSecrets(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	type tc struct {
+		file, contents string
+		changed        []string
+	}
+	cases := []tc{
+		{"app.yaml", "api:\n  token: old-token # the token\nscreen: light\n", []string{"-  token: [redacted] # the token", "+  token: [redacted] # the token"}},
+		{"app.json", "{\n  \"api\": {\n    \"token\": \"old-token\"\n  },\n  \"screen\": \"light\"\n}\n", []string{"-    \"token\": \"[redacted]\"", "+    \"token\": \"[redacted]\""}},
+		{"app.toml", "screen = \"light\"\n\n[api]\ntoken = \"old-token\"\n", []string{"-token = [redacted]", "+token = [redacted]"}},
+	}
+	for _, c := range cases {
+		file := writeConfig(t, dir, c.file, c.contents)
+		b := NewBinder(viper.New())
+		pflags := pflag.NewFlagSet("SaveDiffSecrets", pflag.ContinueOnError)
+		pflags.String("token", "", "")
+		pflags.String("screen", "", "")
+		b.Bind("api.token", pflags.Lookup("token"))
+		b.Bind("screen", pflags.Lookup("screen"))
+		b.MarkSecret("api.token")
+		if err := b.Init(WithConfigFile(file), WithoutEnv()); err != nil {
+			t.Fatalf("%s: unexpected error from Init: %v", c.file, err)
+		}
+		b.Set("api.token", "s3cr3t-value")
+		b.Set("screen", "dark")
+		diff, err := b.SaveDiff("")
+		if err != nil {
+			t.Fatalf("%s: unexpected error from SaveDiff: %v", c.file, err)
+		}
+		if strings.Contains(diff, "old-token") || strings.Contains(diff, "s3cr3t-value") || !strings.Contains(diff, "dark") {
+			t.Errorf("%s: wrong diff:\n%s", c.file, diff)
+		}
+		for _, l := range c.changed {
+			if !strings.Contains(diff, l+"\n") {
+				t.Errorf("%s: diff is missing %q:\n%s", c.file, l, diff)
+			}
+		}
+	}
+}
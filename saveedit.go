@@ -0,0 +1,537 @@
+package vconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+* Editing config files in place.
+*
+* Each editor sets one (dotted) key to a value in the text of a file,
+* leaving everything else as it was. They understand enough of each
+* format to find a key in the usual layouts:
+*
+*   yaml: nested block mappings, by indentation.
+*   toml: [section] tables, and key = value lines in them.
+*   json: nested objects.
+*
+* Anything else, e.g. a yaml flow mapping or a toml value over several
+* lines, gives an error rather than the risk of breaking the file.
+ */
+
+// configEditor sets key to value in src.
+type configEditor func(src []byte, key string, value interface{}) ([]byte, error)
+
+var configEditors = map[string]configEditor{
+	"yaml": editYAML,
+	"yml":  editYAML,
+	"toml": editTOML,
+	"json": editJSON,
+}
+
+// readSettings reads src as format, returning the settings as nested maps.
+func readSettings(src []byte, format string) (map[string]interface{}, error) {
+	if len(bytes.TrimSpace(src)) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(src)); err != nil {
+		return nil, err
+	}
+	return v.AllSettings(), nil
+}
+
+// splitComment splits a line's value from a trailing # comment,
+// which is returned with the space before it.
+func splitComment(rest string) (value, comment string) {
+	var quote byte
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t[{,", rest[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || rest[i-1] == ' ' || rest[i-1] == '\t'):
+			value = strings.TrimRight(rest[:i], " \t")
+			return value, rest[len(value):]
+		}
+	}
+	return strings.TrimRight(rest, " \t"), ""
+}
+
+func indentOf(l string) int {
+	return len(l) - len(strings.TrimLeft(l, " \t"))
+}
+
+func sameKey(a, b string) bool {
+	return strings.EqualFold(strings.Trim(a, `"'`), b)
+}
+
+//
+// YAML
+//
+
+var yamlKeyLine = regexp.MustCompile(`^(\s*)("[^"]*"|'[^']*'|[^\s#"'\-][^:#]*?)\s*:(?:\s+(.*))?$`)
+
+// yamlStructural reports whether a line is part of the structure, rather than blank, a comment or a document marker.
+func yamlStructural(l string) bool {
+	t := strings.TrimSpace(l)
+	return t != "" && !strings.HasPrefix(t, "#") && t != "---" && t != "..."
+}
+
+// yamlBlockEnd returns the index after the last line of the value of the key at lines[i].
+func yamlBlockEnd(lines []string, i int) int {
+	n := indentOf(lines[i])
+	end := i + 1
+	for j := i + 1; j < len(lines); j++ {
+		l := lines[j]
+		if !yamlStructural(l) {
+			continue
+		}
+		t := strings.TrimSpace(l)
+		if indentOf(l) > n || (indentOf(l) == n && (strings.HasPrefix(t, "- ") || t == "-")) {
+			end = j + 1
+			continue
+		}
+		break
+	}
+	return end
+}
+
+// yamlLines renders key: value at indent.
+func yamlLines(indent int, key string, value interface{}, comment string) ([]string, error) {
+	pad := strings.Repeat(" ", indent)
+	out, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	s := strings.TrimSuffix(string(out), "\n")
+	rv := reflect.ValueOf(value)
+	block := value != nil && (rv.Kind() == reflect.Map || rv.Kind() == reflect.Slice) && rv.Len() > 0
+	if !block {
+		return []string{pad + key + ": " + s + comment}, nil
+	}
+	lines := []string{pad + key + ":" + comment}
+	for _, l := range strings.Split(s, "\n") {
+		lines = append(lines, pad+"  "+l)
+	}
+	return lines, nil
+}
+
+func editYAML(src []byte, key string, value interface{}) ([]byte, error) {
+	lines := strings.Split(string(src), "\n")
+	parts := strings.Split(key, ".")
+	start, end, parentIndent := 0, len(lines), -1
+	for pi, p := range parts {
+		// Find the indent of the keys in this block, and the key.
+		childIndent, found, last := -1, -1, -1
+		for i := start; i < end; i++ {
+			l := lines[i]
+			if !yamlStructural(l) {
+				continue
+			}
+			last = i
+			if childIndent < 0 && indentOf(l) > parentIndent {
+				childIndent = indentOf(l)
+			}
+			if indentOf(l) != childIndent {
+				continue
+			}
+			if m := yamlKeyLine.FindStringSubmatch(l); m != nil && sameKey(m[2], p) {
+				found = i
+				break
+			}
+		}
+
+		if found < 0 {
+			// Add the rest of the key at the end of the block.
+			at := start
+			if last >= 0 {
+				at = last + 1
+			} else if pi == 0 {
+				at = 0
+				for i := len(lines) - 1; i >= 0; i-- {
+					if strings.TrimSpace(lines[i]) != "" {
+						at = i + 1
+						break
+					}
+				}
+			}
+			if childIndent < 0 {
+				childIndent = parentIndent + 2
+				if pi == 0 {
+					childIndent = 0
+				}
+			}
+			v := value
+			for i := len(parts) - 1; i > pi; i-- {
+				v = map[string]interface{}{parts[i]: v}
+			}
+			add, err := yamlLines(childIndent, p, v, "")
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines[:at], append(add, lines[at:]...)...)
+			return []byte(strings.Join(lines, "\n")), nil
+		}
+
+		m := yamlKeyLine.FindStringSubmatch(lines[found])
+		val, comment := splitComment(m[3])
+		if pi == len(parts)-1 {
+			add, err := yamlLines(childIndent, m[2], value, comment)
+			if err != nil {
+				return nil, err
+			}
+			be := yamlBlockEnd(lines, found)
+			lines = append(lines[:found], append(add, lines[be:]...)...)
+			return []byte(strings.Join(lines, "\n")), nil
+		}
+		if val != "" {
+			return nil, fmt.Errorf("%s is not a block mapping", strings.Join(parts[:pi+1], "."))
+		}
+		start, end, parentIndent = found+1, yamlBlockEnd(lines, found), childIndent
+	}
+	return src, nil
+}
+
+//
+// TOML
+//
+
+var (
+	tomlHeader  = regexp.MustCompile(`^\s*\[\s*([^\[\]]+?)\s*\]\s*(#.*)?$`)
+	tomlArray   = regexp.MustCompile(`^\s*\[\[`)
+	tomlKeyLine = regexp.MustCompile(`^(\s*)((?:"[^"]*"|'[^']*'|[A-Za-z0-9_\-]+)(?:\s*\.\s*(?:"[^"]*"|'[^']*'|[A-Za-z0-9_\-]+))*)(\s*=\s*)(.*)$`)
+)
+
+// tomlName normalizes a table or key name for comparison.
+func tomlName(n string) string {
+	ps := strings.Split(n, ".")
+	for i, p := range ps {
+		ps[i] = strings.ToLower(strings.Trim(strings.TrimSpace(p), `"'`))
+	}
+	return strings.Join(ps, ".")
+}
+
+// tomlTable is a [table] and the lines of its body.
+type tomlTable struct {
+	name       string // "" for the keys before the first table.
+	header     int    // Line of the header, -1 for the root.
+	start, end int    // The body.
+}
+
+func tomlTables(lines []string) []tomlTable {
+	tables := []tomlTable{{header: -1, start: 0, end: len(lines)}}
+	for i, l := range lines {
+		if m := tomlHeader.FindStringSubmatch(l); m != nil || tomlArray.MatchString(l) {
+			tables[len(tables)-1].end = i
+			t := tomlTable{name: "\x00", header: i, start: i + 1, end: len(lines)}
+			if m != nil && !tomlArray.MatchString(l) {
+				t.name = tomlName(m[1])
+			}
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+func editTOML(src []byte, key string, value interface{}) ([]byte, error) {
+	lines := strings.Split(string(src), "\n")
+	tables := tomlTables(lines)
+	parts := strings.Split(key, ".")
+	val, err := tomlValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	// The key may be in any table above it, as a dotted key.
+	for _, t := range tables {
+		if t.name != "" && !strings.HasPrefix(key+".", t.name+".") {
+			continue
+		}
+		name := key
+		if t.name != "" {
+			name = strings.TrimPrefix(key, t.name+".")
+		}
+		for i := t.start; i < t.end; i++ {
+			m := tomlKeyLine.FindStringSubmatch(lines[i])
+			if m == nil || tomlName(m[2]) != name {
+				continue
+			}
+			old, comment := splitComment(m[4])
+			if strings.HasPrefix(old, `"""`) || strings.HasPrefix(old, "'''") ||
+				strings.Count(old, "[")+strings.Count(old, "{") != strings.Count(old, "]")+strings.Count(old, "}") {
+				return nil, fmt.Errorf("%s has a value over several lines", key)
+			}
+			lines[i] = m[1] + m[2] + m[3] + val + comment
+			return []byte(strings.Join(lines, "\n")), nil
+		}
+	}
+
+	// Add it to its table, making the table if need be.
+	table := strings.Join(parts[:len(parts)-1], ".")
+	line := parts[len(parts)-1] + " = " + val
+	for _, t := range tables {
+		if t.name != table {
+			continue
+		}
+		at := t.start
+		if t.header < 0 {
+			at = 0
+		}
+		for i := t.start; i < t.end; i++ {
+			if s := strings.TrimSpace(lines[i]); s != "" && !strings.HasPrefix(s, "#") {
+				at = i + 1
+			}
+		}
+		if t.header < 0 && at == 0 && len(tables) > 1 {
+			// No keys before the first table, put it just before it.
+			lines = append(lines[:tables[1].header], append([]string{line, ""}, lines[tables[1].header:]...)...)
+		} else {
+			lines = append(lines[:at], append([]string{line}, lines[at:]...)...)
+		}
+		return []byte(strings.Join(lines, "\n")), nil
+	}
+	// Put the new table at the end.
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, "["+table+"]", line, "")
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// tomlValue formats v as a TOML value.
+func tomlValue(v interface{}) (string, error) {
+	switch tv := v.(type) {
+	case string:
+		return strconv.Quote(tv), nil
+	case bool:
+		return strconv.FormatBool(tv), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(tv), nil
+	case float32:
+		return tomlFloat(float64(tv)), nil
+	case float64:
+		return tomlFloat(tv), nil
+	case time.Time:
+		return tv.Format(time.RFC3339Nano), nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		vals := make([]string, rv.Len())
+		for i := range vals {
+			s, err := tomlValue(rv.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			vals[i] = s
+		}
+		return "[" + strings.Join(vals, ", ") + "]", nil
+	case reflect.Map:
+		var kvs []string
+		for _, k := range rv.MapKeys() {
+			s, err := tomlValue(rv.MapIndex(k).Interface())
+			if err != nil {
+				return "", err
+			}
+			kvs = append(kvs, fmt.Sprint(k.Interface())+" = "+s)
+		}
+		sort.Strings(kvs)
+		return "{" + strings.Join(kvs, ", ") + "}", nil
+	}
+	return "", fmt.Errorf("can't write %T as toml", v)
+}
+
+func tomlFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEn") {
+		s += ".0"
+	}
+	return s
+}
+
+//
+// JSON
+//
+
+type jsonMember struct {
+	key                        string
+	keyStart, valStart, valEnd int
+}
+
+// jsonObject reads the members of the object starting at src[at], returning them
+// and the index of its closing brace.
+func jsonObject(src []byte, at int) (members []jsonMember, end int, err error) {
+	ws := func(i int) int {
+		for i < len(src) && strings.ContainsRune(" \t\r\n", rune(src[i])) {
+			i++
+		}
+		return i
+	}
+	value := func(i int) (int, error) {
+		dec := json.NewDecoder(bytes.NewReader(src[i:]))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return 0, err
+		}
+		return i + int(dec.InputOffset()), nil
+	}
+
+	i := ws(at + 1)
+	if i < len(src) && src[i] == '}' {
+		return nil, i, nil
+	}
+	for i < len(src) {
+		var m jsonMember
+		m.keyStart = i
+		ke, err := value(i)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal(src[i:ke], &m.key); err != nil {
+			return nil, 0, err
+		}
+		i = ws(ke)
+		if i >= len(src) || src[i] != ':' {
+			return nil, 0, fmt.Errorf("expected : at offset %d", i)
+		}
+		m.valStart = ws(i + 1)
+		if m.valEnd, err = value(m.valStart); err != nil {
+			return nil, 0, err
+		}
+		members = append(members, m)
+		i = ws(m.valEnd)
+		if i < len(src) && src[i] == '}' {
+			return members, i, nil
+		}
+		if i >= len(src) || src[i] != ',' {
+			return nil, 0, fmt.Errorf("expected , or } at offset %d", i)
+		}
+		i = ws(i + 1)
+	}
+	return nil, 0, fmt.Errorf("unexpected end of json")
+}
+
+// lineIndent is the indentation of the line src[i] is on.
+func lineIndent(src []byte, i int) string {
+	s := bytes.LastIndexByte(src[:i], '\n') + 1
+	e := s
+	for e < len(src) && (src[e] == ' ' || src[e] == '\t') {
+		e++
+	}
+	return string(src[s:e])
+}
+
+func editJSON(src []byte, key string, value interface{}) ([]byte, error) {
+	if len(bytes.TrimSpace(src)) == 0 {
+		src = []byte("{}\n")
+	}
+	obj := bytes.IndexByte(src, '{')
+	if obj < 0 || len(bytes.TrimSpace(src[:obj])) != 0 {
+		return nil, fmt.Errorf("not a json object")
+	}
+	parts := strings.Split(key, ".")
+	for pi, p := range parts {
+		members, end, err := jsonObject(src, obj)
+		if err != nil {
+			return nil, err
+		}
+		indent := lineIndent(src, obj)
+		unit := "  "
+		if len(members) > 0 {
+			if mi := lineIndent(src, members[0].keyStart); len(mi) > len(indent) {
+				unit = mi[len(indent):]
+			}
+		}
+
+		// Keep objects written on one line that way.
+		multiline := len(members) == 0 || bytes.ContainsRune(src[obj:end], '\n')
+		marshal := func(v interface{}) ([]byte, error) {
+			if multiline {
+				return json.MarshalIndent(v, indent+unit, unit)
+			}
+			return json.Marshal(v)
+		}
+
+		var found *jsonMember
+		for i := range members {
+			if strings.EqualFold(members[i].key, p) {
+				found = &members[i]
+				break
+			}
+		}
+
+		if found == nil {
+			v := value
+			for i := len(parts) - 1; i > pi; i-- {
+				v = map[string]interface{}{parts[i]: v}
+			}
+			out, err := marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			k, _ := json.Marshal(p)
+			member := string(k) + ": " + string(out)
+			var add string
+			at := end
+			if len(members) == 0 {
+				add = "\n" + indent + unit + member + "\n" + indent
+				at = obj + 1
+				// Drop whatever space was between the braces.
+				src = append(src[:at:at], src[end:]...)
+			} else {
+				last := members[len(members)-1]
+				at = last.valEnd
+				if multiline {
+					add = ",\n" + lineIndent(src, last.keyStart) + member
+				} else {
+					add = ", " + member
+				}
+			}
+			return append(src[:at:at], append([]byte(add), src[at:]...)...), nil
+		}
+
+		if pi == len(parts)-1 {
+			out, err := marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			return append(src[:found.valStart:found.valStart], append(out, src[found.valEnd:]...)...), nil
+		}
+		if src[found.valStart] != '{' {
+			return nil, fmt.Errorf("%s is not an object", strings.Join(parts[:pi+1], "."))
+		}
+		obj = found.valStart
+	}
+	return src, nil
+}
@@ -0,0 +1,468 @@
+package vconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+/*
+* Checking the config against a schema.
+*
+* A Schema is the subset of JSON Schema that's useful for config files:
+* types, required keys, enums, ranges, patterns and nested objects.
+* It can be built in go, or read from a JSON Schema file with ParseSchema.
+*
+* Init and every Reload check the config against the schema given with
+* WithSchema, and Set won't take a value that doesn't fit. Values are
+* often strings, from the environment or a sloppy file, so a string is
+* taken as a boolean or a number if it can be parsed as one: "true" is a
+* fine boolean, "ture" is not.
+ */
+
+// Schema types.
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+)
+
+// Schema describes the values allowed for a key, or for the whole config.
+// The zero Schema allows anything.
+type Schema struct {
+	Type        string             `json:"type,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	// Only the keys in Properties are allowed when this is false.
+	AdditionalProperties *bool         `json:"additionalProperties,omitempty"`
+	Items                *Schema       `json:"items,omitempty"`
+	Enum                 []interface{} `json:"enum,omitempty"`
+	Minimum              *float64      `json:"minimum,omitempty"`
+	Maximum              *float64      `json:"maximum,omitempty"`
+	Pattern              string        `json:"pattern,omitempty"`
+}
+
+// SchemaError is a value that doesn't fit the schema.
+// File and Line are set when the value came from a config file and the key can be found in it.
+type SchemaError struct {
+	Key     string
+	Value   interface{}
+	File    string
+	Line    int
+	Problem string
+}
+
+func (e *SchemaError) Error() string {
+	switch {
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s %s", e.File, e.Line, e.Key, e.Problem)
+	case e.File != "":
+		return fmt.Sprintf("%s: %s %s", e.File, e.Key, e.Problem)
+	}
+	return fmt.Sprintf("%s %s", e.Key, e.Problem)
+}
+
+// ParseSchema reads a JSON Schema.
+func ParseSchema(data []byte) (*Schema, error) {
+	s := new(Schema)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("can't read schema: %w", err)
+	}
+	if err := s.check(""); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ReadSchema reads a JSON Schema file.
+func ReadSchema(path string) (*Schema, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := ParseSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// check looks for mistakes in the schema itself.
+func (s *Schema) check(key string) error {
+	switch s.Type {
+	case "", TypeObject, TypeArray, TypeString, TypeInteger, TypeNumber, TypeBoolean:
+	default:
+		return fmt.Errorf("schema for %q: unknown type %q", key, s.Type)
+	}
+	if s.Pattern != "" {
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("schema for %q: %w", key, err)
+		}
+	}
+	for k, ps := range s.Properties {
+		if err := ps.check(joinKey(key, k)); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.check(key + "[]")
+	}
+	return nil
+}
+
+// WithSchema checks the config against s when it's read, and values given to Set.
+func WithSchema(s *Schema) Option {
+	return func(o *options) { o.schema = s }
+}
+
+// Validate checks the current config against the schema from Init,
+// returning a SchemaError for each problem.
+func Validate() error {
+	return std.Validate()
+}
+
+// Validate checks this Binder's config against the schema from Init.
+// See the package level Validate.
+func (b *Binder) Validate() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.validate()
+}
+
+// validate checks the effective config against the schema. b.mu must be held.
+func (b *Binder) validate() error {
+	if b.opts == nil || b.opts.schema == nil {
+		return nil
+	}
+	errs := b.opts.schema.validate("", b.Viper().AllSettings(), b.isSecret)
+	var el errorList
+	for _, e := range errs {
+		if l, ok := b.layerFor(e.Key); ok {
+			e.File = l.File
+			e.Line = keyLine(l.File, e.Key)
+		}
+		el = el.add(e)
+	}
+	return el.err()
+}
+
+// checkValue checks a value for key against the schema from Init. b.mu must be held.
+func (b *Binder) checkValue(key string, value interface{}) error {
+	if b.opts == nil || b.opts.schema == nil || value == nil {
+		return nil
+	}
+	s := b.opts.schema
+	parts := strings.Split(strings.ToLower(key), ".")
+	// A value inside a secret one is secret too, as it is when validate starts from the top.
+	secret := b.isSecret
+	if b.isSecretIn(key) {
+		secret = func(string) bool { return true }
+	}
+	for i, p := range parts {
+		ps := s.property(p)
+		if ps == nil {
+			if s.closed() {
+				v := value
+				if secret(key) {
+					v = Redacted
+				}
+				return &SchemaError{Key: key, Value: v, Problem: fmt.Sprintf("isn't allowed in %s", objectName(parts[:i]))}
+			}
+			return nil
+		}
+		s = ps
+	}
+	var el errorList
+	for _, e := range s.validate(key, value, secret) {
+		el = el.add(e)
+	}
+	return el.err()
+}
+
+// loadChecked reads the config files and checks them against the schema.
+// With keep, a config that doesn't fit is dropped for the one that was there before.
+// b.mu must be held.
+func (b *Binder) loadChecked(keep bool) error {
+	layers, discovery, includes := b.layers, b.discovery, b.includes
+	profile, profileFiles, profileNames := b.profile, b.profileFiles, b.profileNames
+	if err := b.load(); err != nil {
+		return err
+	}
+	err := b.validate()
+	if err != nil && keep {
+		if b.Debug() {
+			fmt.Printf("Config doesn't fit the schema, keeping the old one: %v\n", err)
+		}
+		merged := make(map[string]interface{})
+		file, configType := "", b.opts.configType
+		for _, l := range layers {
+			mergeSettings(merged, l.settings)
+			if l.Name != ProfileLayer && l.Name != IncludeLayer {
+				file = l.File
+			}
+		}
+		if file == "" && configType == "" {
+			configType = "yaml"
+		}
+		if ierr := installConfig(b.Viper(), file, configType, merged); ierr != nil {
+			return ierr
+		}
+		b.layers, b.discovery, b.includes = layers, discovery, includes
+		b.profile, b.profileFiles, b.profileNames = profile, profileFiles, profileNames
+	}
+	return err
+}
+
+func objectName(parts []string) string {
+	if len(parts) == 0 {
+		return "the config"
+	}
+	return strings.Join(parts, ".")
+}
+
+// property returns the schema for the property named name, ignoring case as viper does.
+func (s *Schema) property(name string) *Schema {
+	if ps, ok := s.Properties[name]; ok {
+		return ps
+	}
+	for k, ps := range s.Properties {
+		if strings.EqualFold(k, name) {
+			return ps
+		}
+	}
+	return nil
+}
+
+func (s *Schema) closed() bool {
+	return s.AdditionalProperties != nil && !*s.AdditionalProperties
+}
+
+// validate checks v, the value for key, against s.
+// The values of keys that secret says are secret, and what's in them, are Redacted in the errors.
+func (s *Schema) validate(key string, v interface{}, secret func(string) bool) (errs []*SchemaError) {
+	if v == nil {
+		return nil
+	}
+	hide := secret(key)
+	if hide { // And everything in it.
+		secret = func(string) bool { return true }
+	}
+	fail := func(format string, args ...interface{}) {
+		sv := v
+		if hide {
+			sv = Redacted
+		}
+		errs = append(errs, &SchemaError{Key: key, Value: sv, Problem: fmt.Sprintf(format, args...)})
+	}
+	// show formats v for a problem.
+	show := func(format string, v interface{}) string {
+		if hide {
+			return Redacted
+		}
+		return fmt.Sprintf(format, v)
+	}
+	name := key
+	if name == "" {
+		name = "the config"
+	}
+
+	typ := s.Type
+	if typ == "" && (len(s.Properties) > 0 || len(s.Required) > 0) {
+		typ = TypeObject
+	}
+	switch typ {
+	case TypeObject:
+		m, err := cast.ToStringMapE(v)
+		if err != nil {
+			fail("should be an object, not %s", show("%s", describe(v)))
+			return errs
+		}
+		for _, r := range s.Required {
+			found := false
+			for k := range m {
+				found = found || strings.EqualFold(k, r)
+			}
+			if !found {
+				errs = append(errs, &SchemaError{Key: joinKey(key, strings.ToLower(r)), Problem: "is required"})
+			}
+		}
+		for _, k := range sortedKeys(m) {
+			ps := s.property(k)
+			if ps == nil {
+				if s.closed() {
+					kv := m[k]
+					if secret(joinKey(key, k)) {
+						kv = Redacted
+					}
+					errs = append(errs, &SchemaError{Key: joinKey(key, k), Value: kv, Problem: fmt.Sprintf("isn't allowed in %s", name)})
+				}
+				continue
+			}
+			errs = append(errs, ps.validate(joinKey(key, k), m[k], secret)...)
+		}
+		return errs
+	case TypeArray:
+		rv := reflect.ValueOf(v)
+		if _, ok := v.(string); ok { // Split later by viper, e.g. from the environment.
+			return nil
+		}
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			fail("should be an array, not %s", show("%s", describe(v)))
+			return errs
+		}
+		if s.Items != nil {
+			for i := 0; i < rv.Len(); i++ {
+				errs = append(errs, s.Items.validate(fmt.Sprintf("%s[%d]", key, i), rv.Index(i).Interface(), secret)...)
+			}
+		}
+		return errs
+	case TypeString:
+		switch v.(type) {
+		case string, fmt.Stringer:
+		default:
+			fail("should be a string, not %s", show("%s", describe(v)))
+			return errs
+		}
+	case TypeBoolean:
+		if _, ok := v.(bool); !ok {
+			if s, isString := v.(string); !isString {
+				fail("should be a boolean, not %s", show("%s", describe(v)))
+				return errs
+			} else if _, err := strconv.ParseBool(s); err != nil {
+				fail("should be a boolean, not %s", show("%q", s))
+				return errs
+			}
+		}
+	case TypeInteger, TypeNumber:
+		f, ok := numeric(v)
+		if !ok {
+			fail("should be %s, not %s", article(s.Type), show("%s", describe(v)))
+			return errs
+		}
+		if s.Type == TypeInteger && f != math.Trunc(f) {
+			fail("should be an integer, not %s", show("%v", v))
+			return errs
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			fail("should be at least %v, not %s", *s.Minimum, show("%v", v))
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("should be at most %v, not %s", *s.Maximum, show("%v", v))
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			found = found || fmt.Sprint(e) == fmt.Sprint(v)
+		}
+		if !found {
+			fail("should be one of %v, not %s", s.Enum, show("%v", v))
+		}
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			fail("has a bad pattern in the schema: %v", err)
+		} else if str := fmt.Sprint(v); !re.MatchString(str) {
+			fail("should match %s, not %s", s.Pattern, show("%q", str))
+		}
+	}
+	return errs
+}
+
+// numeric returns v as a float, if it's a number or a string holding one.
+func numeric(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	case bool:
+		return 0, false
+	}
+	f, err := cast.ToFloat64E(v)
+	return f, err == nil
+}
+
+func article(t string) string {
+	if t == TypeInteger {
+		return "an integer"
+	}
+	return "a " + t
+}
+
+// describe names the kind of value v is, for errors.
+func describe(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case map[string]interface{}, map[interface{}]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// keyLine finds the line that sets key in a config file, or 0 if it can't.
+// It looks for each part of the key in turn, which is good enough for
+// the usual layouts without parsing the file again.
+func keyLine(file, key string) int {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0
+	}
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(file), "."))
+	return keyLineIn(strings.Split(string(data), "\n"), key, format)
+}
+
+// keyLineIn finds the line in the lines of a config file in format that sets key, or 0.
+func keyLineIn(lines []string, key, format string) int {
+	parts := strings.Split(key, ".")
+	found := 0
+	for i := 0; i < len(lines) && len(parts) > 0; i++ {
+		if lineHasKey(lines[i], parts[0], format) {
+			found = i + 1
+			parts = parts[1:]
+		}
+	}
+	if len(parts) > 0 {
+		return 0
+	}
+	return found
+}
+
+func lineHasKey(l, part, format string) bool {
+	t := strings.TrimSpace(l)
+	switch format {
+	case "yaml", "yml":
+		t = strings.TrimPrefix(t, "- ")
+		return strings.HasPrefix(strings.ToLower(t), part+":")
+	case "toml":
+		if strings.HasPrefix(t, "[") {
+			names := strings.Split(strings.Trim(t, "[] "), ".")
+			return tomlName(names[len(names)-1]) == part
+		}
+		if eq := strings.Index(t, "="); eq > 0 {
+			return tomlName(strings.TrimSpace(t[:eq])) == part
+		}
+	case "json":
+		lt := strings.ToLower(t)
+		if i := strings.Index(lt, `"`+part+`"`); i >= 0 {
+			return strings.HasPrefix(strings.TrimSpace(lt[i+len(part)+2:]), ":")
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package vconfig
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const testSchema = `{
+  "type": "object",
+  "required": ["screen"],
+  "additionalProperties": false,
+  "properties": {
+    "screen": {"type": "string", "enum": ["light", "dark"]},
+    "verbose": {"type": "boolean"},
+    "debug": {"type": "boolean"},
+    "name": {"type": "string", "pattern": "^[a-z]+$"},
+    "server": {
+      "type": "object",
+      "properties": {
+        "port": {"type": "integer", "minimum": 1, "maximum": 65535},
+        "hosts": {"type": "array", "items": {"type": "string"}}
+      }
+    }
+  }
+}`
+
+func schemaErrors(err error) (ses []*SchemaError) {
+	var el errorList
+	var se *SchemaError
+	switch {
+	case errors.As(err, &el):
+		for _, e := range el {
+			if errors.As(e, &se) {
+				ses = append(ses, se)
+			}
+		}
+	case errors.As(err, &se):
+		ses = append(ses, se)
+	}
+	return ses
+}
+
+func TestSchemaValidate(t *testing.T) {
+	s, err := ParseSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseSchema: %v", err)
+	}
+
+	type tc struct {
+		settings map[string]interface{}
+		expected []string // The keys with problems.
+	}
+	cases := []tc{
+		{map[string]interface{}{"screen": "dark", "verbose": "true", "server": map[string]interface{}{"port": "8080"}}, nil},
+		{map[string]interface{}{"screen": "dark", "verbose": "ture"}, []string{"verbose"}},
+		{map[string]interface{}{"verbose": true}, []string{"screen"}},
+		{map[string]interface{}{"screen": "blue", "name": "Bad Name"}, []string{"name", "screen"}},
+		{map[string]interface{}{"screen": "dark", "server": map[string]interface{}{"port": 70000}}, []string{"server.port"}},
+		{map[string]interface{}{"screen": "dark", "server": map[string]interface{}{"port": 1.5}}, []string{"server.port"}},
+		{map[string]interface{}{"screen": "dark", "server": map[string]interface{}{"hosts": []interface{}{"a", 1}}}, []string{"server.hosts[1]"}},
+		{map[string]interface{}{"screen": "dark", "server": "localhost"}, []string{"server"}},
+		{map[string]interface{}{"screen": "dark", "sreen": "light"}, []string{"sreen"}},
+	}
+	for i, c := range cases {
+		errs := s.validate("", c.settings, func(string) bool { return false })
+		var got []string
+		for _, e := range errs {
+			got = append(got, e.Key)
+		}
+		if len(got) != len(c.expected) {
+			t.Errorf("Case %d: wrong problems. Got: %q, Expected: %q", i, got, c.expected)
+			continue
+		}
+		for j := range got {
+			if got[j] != c.expected[j] {
+				t.Errorf("Case %d: wrong problems. Got: %q, Expected: %q", i, got, c.expected)
+				break
+			}
+		}
+	}
+
+	for _, bad := range []string{`{"type": "thing"}`, `{"properties": {"a": {"pattern": "("}}}`, `{"type": 1}`} {
+		if _, err := ParseSchema([]byte(bad)); err == nil {
+			t.Errorf("Expected an error parsing schema %s", bad)
+		}
+	}
+}
+
+func TestSchemaInit(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := writeConfig(t, dir, "app.yaml", "screen: dark\nverbose: ture\nserver:\n  port: 0\n")
+	s, _ := ParseSchema([]byte(testSchema))
+
+	// Init reports all the problems, with where they are.
+	b := NewBinder(viper.New())
+	err := b.Init(WithConfigFile(file), WithoutEnv(), WithSchema(s))
+	ses := schemaErrors(err)
+	if len(ses) != 2 {
+		t.Fatalf("Wrong errors from Init. Got: %v", err)
+	}
+	if ses[0].Key != "server.port" || ses[0].File != file || ses[0].Line != 4 {
+		t.Errorf("Wrong error. Got: %#v", ses[0])
+	}
+	if ses[1].Key != "verbose" || ses[1].Line != 2 {
+		t.Errorf("Wrong error. Got: %#v", ses[1])
+	}
+
+	// Fix the file and a reload is fine, break it again and the good config stays.
+	writeConfig(t, dir, "app.yaml", "screen: light\nserver:\n  port: 80\n")
+	if err := b.Reload(); err != nil {
+		t.Fatalf("Unexpected error from Reload: %v", err)
+	}
+	writeConfig(t, dir, "app.yaml", "screen: purple\nserver:\n  port: 81\n")
+	if ses := schemaErrors(b.Reload()); len(ses) != 1 || ses[0].Key != "screen" || ses[0].Line != 1 {
+		t.Errorf("Wrong errors from Reload. Got: %#v", ses)
+	}
+	if g := b.Viper().Get("screen"); g != "light" {
+		t.Errorf("Bad reload replaced the config. Got: %#v, Expected: %#v", g, "light")
+	}
+	if g := b.Viper().GetInt("server.port"); g != 80 {
+		t.Errorf("Bad reload replaced the config. Got: %#v, Expected: %#v", g, 80)
+	}
+
+	// Set checks values.
+	type tc struct {
+		key   string
+		value interface{}
+		ok    bool
+	}
+	for i, c := range []tc{
+		{"verbose", "ture", false},
+		{"verbose", "true", true},
+		{"verbose", true, true},
+		{"server.port", 99999, false},
+		{"server.port", 8080, true},
+		{"server.extra", 1, true},
+		{"sreen", "dark", false},
+		{"screen", "dark", true},
+	} {
+		err := b.Set(c.key, c.value)
+		if (err == nil) != c.ok {
+			t.Errorf("Case %d: wrong result from Set(%q, %#v). Got: %v", i, c.key, c.value, err)
+		}
+	}
+	if g := b.Viper().Get("verbose"); g != true {
+		t.Errorf("Wrong value after Sets. Got: %#v, Expected: true", g)
+	}
+
+	// So does Toggle.
+	if v, err := b.ToggleE("sreen"); err == nil || v || b.Viper().IsSet("sreen") {
+		t.Errorf("Wrong result from ToggleE of a key the schema doesn't allow. Got: %#v, %v", v, err)
+	}
+	if v := b.Toggle("sreen"); v || b.Viper().IsSet("sreen") {
+		t.Errorf("Wrong result from Toggle of a key the schema doesn't allow. Got: %#v", v)
+	}
+	if v, err := b.ToggleE("verbose"); err != nil || v {
+		t.Errorf("Wrong result from ToggleE. Got: %#v, %v", v, err)
+	}
+	if err := b.Validate(); err != nil {
+		t.Errorf("Unexpected error from Validate: %v", err)
+	}
+}
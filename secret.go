@@ -0,0 +1,298 @@
+package vconfig
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+)
+
+/*
+* Secrets.
+*
+* Keys holding API tokens, passwords and the like can be marked secret,
+* with MarkSecret, a Key's AsSecret, a secret:"true" struct tag, or by
+* marking the flag bound to them with MarkFlagSecret. Their values are
+* shown as Redacted wherever we print them: debug output, flagString,
+* Explanation.String, SaveDiff, the journal file and the errors about
+* their values.
+*
+* The value of a secret key can refer to where the secret is kept,
+* rather than being the secret:
+*
+*	api:
+*	  token: file:///run/secrets/token
+*	  other: env:OTHER_TOKEN
+*	  third: cmd:pass show api/third
+*
+* The reference stays in viper (and is what Save writes). SecretValue,
+* and a secret Key's Get, resolve it with the Resolver registered for its
+* prefix the first time they're asked, and keep the result in memory only,
+* until the config is read again or ForgetSecrets is called.
+*
+* Only file:// and env: are registered to start with. Running commands
+* from cmd: means anyone who can write a config file we read, including
+* one in the current directory, can run what they like, so it has to be
+* asked for:
+*
+*	vconfig.RegisterResolver("cmd:", vconfig.CmdResolver)
+ */
+
+// Redacted is shown in place of a secret value.
+const Redacted = "[redacted]"
+
+// SecretAnnotation is the pflag annotation that marks a flag as secret.
+const SecretAnnotation = "vconfig_secret"
+
+// Resolver finds the secret a reference refers to.
+type Resolver interface {
+	// Resolve is given the reference without its prefix, e.g. "/run/secrets/token" for "file:///run/secrets/token".
+	Resolve(ref string) (string, error)
+}
+
+// ResolverFunc makes a Resolver from a function.
+type ResolverFunc func(ref string) (string, error)
+
+// Resolve calls f.
+func (f ResolverFunc) Resolve(ref string) (string, error) { return f(ref) }
+
+var (
+	resolversMu sync.RWMutex
+	resolvers   = map[string]Resolver{
+		"file://": ResolverFunc(resolveFile),
+		"env:":    ResolverFunc(resolveEnv),
+	}
+)
+
+// CmdResolver runs the command in a reference, without a shell, and resolves it to
+// what the command prints. It isn't registered unless you register it, see RegisterResolver.
+var CmdResolver Resolver = ResolverFunc(resolveCmd)
+
+// RegisterResolver registers r for secret values that start with prefix, e.g. "vault:".
+// It replaces any Resolver already registered for prefix, including the built in
+// ones for "file://" and "env:".
+func RegisterResolver(prefix string, r Resolver) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	resolvers[prefix] = r
+}
+
+// UnregisterResolver removes the Resolver for prefix.
+func UnregisterResolver(prefix string) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	delete(resolvers, prefix)
+}
+
+// resolverFor returns the Resolver for ref and the reference without its prefix.
+// The longest matching prefix wins.
+func resolverFor(ref string) (Resolver, string, bool) {
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+	prefixes := make([]string, 0, len(resolvers))
+	for p := range resolvers {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	for _, p := range prefixes {
+		if strings.HasPrefix(ref, p) {
+			return resolvers[p], strings.TrimPrefix(ref, p), true
+		}
+	}
+	return nil, "", false
+}
+
+func resolveFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func resolveEnv(name string) (string, error) {
+	if v, ok := os.LookupEnv(name); ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("$%s isn't set", name)
+}
+
+// resolveCmd runs the command, without a shell, and returns what it prints.
+func resolveCmd(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", fmt.Errorf("no command")
+	}
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", args[0], err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// MarkSecret marks the keys as secret, so their values are redacted
+// when printed, and resolved by SecretValue with the registered Resolvers.
+func MarkSecret(keys ...string) {
+	std.MarkSecret(keys...)
+}
+
+// MarkSecret marks the keys as secret in this Binder.
+// See the package level MarkSecret.
+func (b *Binder) MarkSecret(keys ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, k := range keys {
+		b.markSecret(k)
+	}
+}
+
+// markSecret does the work of MarkSecret. b.mu must be held.
+func (b *Binder) markSecret(key string) {
+	b.secrets[strings.ToLower(key)] = true
+	if bf := b.binding(key); bf != nil {
+		for _, f := range bf.flags {
+			MarkFlagSecret(f)
+		}
+	}
+}
+
+// MarkFlagSecret marks f as secret, so its value is redacted when printed.
+// The key it's bound to is secret too.
+func MarkFlagSecret(f *pflag.Flag) {
+	if f.Annotations == nil {
+		f.Annotations = make(map[string][]string)
+	}
+	f.Annotations[SecretAnnotation] = []string{"true"}
+}
+
+func isSecretFlag(f *pflag.Flag) bool {
+	return f != nil && len(f.Annotations[SecretAnnotation]) > 0
+}
+
+// IsSecret returns whether key has been marked secret.
+func IsSecret(key string) bool {
+	return std.IsSecret(key)
+}
+
+// IsSecret returns whether key has been marked secret in this Binder.
+func (b *Binder) IsSecret(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.isSecret(key)
+}
+
+// isSecret returns whether key is marked secret, by MarkSecret, its Key
+// or one of its flags. b.mu must be held.
+func (b *Binder) isSecret(key string) bool {
+	if b.secrets[strings.ToLower(key)] {
+		return true
+	}
+	catalogMu.Lock()
+	ki := catalog[key]
+	catalogMu.Unlock()
+	if ki.Secret {
+		return true
+	}
+	if bf := b.binding(key); bf != nil {
+		for _, f := range bf.flags {
+			if isSecretFlag(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isSecretIn returns whether key, or a key it's in, is secret. b.mu must be held.
+func (b *Binder) isSecretIn(key string) bool {
+	parts := strings.Split(key, ".")
+	for i := range parts {
+		if b.isSecret(strings.Join(parts[:i+1], ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns v, or Redacted if key is secret and there's a value to hide.
+// b.mu must be held.
+func (b *Binder) redact(key string, v interface{}) interface{} {
+	if v != nil && b.isSecret(key) {
+		return Redacted
+	}
+	return v
+}
+
+// redactor is an error that can hide the value it reports.
+type redactor interface {
+	redact()
+}
+
+// redactErr hides the value err reports, if key is secret. b.mu must be held.
+func (b *Binder) redactErr(key string, err error) error {
+	var r redactor
+	if err != nil && errors.As(err, &r) && b.isSecret(key) {
+		r.redact()
+	}
+	return err
+}
+
+// SecretValue returns the value of key, resolved if it refers to a secret kept
+// elsewhere, e.g. file:///run/secrets/token. Resolved values are kept in memory
+// until the config is read again, or ForgetSecrets is called.
+// Values that don't start with the prefix of a registered Resolver are returned as they are.
+func SecretValue(key string) (string, error) {
+	return std.SecretValue(key)
+}
+
+// SecretValue returns the value of key in this Binder, resolved.
+// See the package level SecretValue.
+func (b *Binder) SecretValue(key string) (string, error) {
+	b.mu.Lock()
+	v := b.get(key)
+	b.mu.Unlock()
+	return b.resolve(key, cast.ToString(v))
+}
+
+// resolve returns the secret ref refers to, from the cache if it's been resolved before.
+// b.mu must not be held, as a Resolver can take a while.
+func (b *Binder) resolve(key, ref string) (string, error) {
+	r, rest, ok := resolverFor(ref)
+	if !ok {
+		return ref, nil
+	}
+	b.secretMu.Lock()
+	defer b.secretMu.Unlock()
+	if s, ok := b.secretCache[ref]; ok {
+		return s, nil
+	}
+	s, err := r.Resolve(rest)
+	if err != nil {
+		// The reference isn't the secret, but may say something about it.
+		return "", fmt.Errorf("resolving secret %s: %w", key, err)
+	}
+	if b.secretCache == nil {
+		b.secretCache = make(map[string]string)
+	}
+	b.secretCache[ref] = s
+	return s, nil
+}
+
+// ForgetSecrets drops the resolved secrets, so they're resolved again when next asked for.
+func ForgetSecrets() {
+	std.ForgetSecrets()
+}
+
+// ForgetSecrets drops the resolved secrets of this Binder.
+func (b *Binder) ForgetSecrets() {
+	b.secretMu.Lock()
+	defer b.secretMu.Unlock()
+	b.secretCache = nil
+}
@@ -0,0 +1,289 @@
+package vconfig
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var (
+	testSecretToken = NewKey("secrets.env", "A token.", "").AsSecret()
+	testNotSecret   = NewKey("plain", "Not a secret.", "")
+)
+
+// stdout returns what fn prints.
+func stdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Unexpected error from Pipe: %v", err)
+	}
+	saved := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		out, _ := ioutil.ReadAll(r)
+		done <- out
+	}()
+	fn()
+	os.Stdout = saved
+	w.Close()
+	return string(<-done)
+}
+
+func TestSecretRedaction(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := writeConfig(t, dir, "app.yaml", "api:\n  token: file-token\n")
+	journal := filepath.Join(dir, "journal")
+
+	b := NewBinder(viper.New())
+	if err := b.Init(WithConfigFile(file), WithoutEnv(), WithJournalFile(journal)); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+	pflags := pflag.NewFlagSet("Secrets", pflag.ContinueOnError)
+	pflags.String("token", "default-token", "")
+	pflags.String("password", "", "")
+	pflags.String("user", "", "")
+	b.MarkSecret("api.token")
+	b.Bind("api.token", pflags.Lookup("token"))
+	MarkFlagSecret(pflags.Lookup("password"))
+	b.Bind("db.password", pflags.Lookup("password"))
+	b.Bind("db.user", pflags.Lookup("user"))
+
+	for _, k := range []string{"api.token", "API.Token", "db.password"} {
+		if !b.IsSecret(k) {
+			t.Errorf("Expected %q to be secret.", k)
+		}
+	}
+	if b.IsSecret("db.user") {
+		t.Errorf("Expected db.user not to be secret.")
+	}
+
+	pflags.Parse([]string{"--token", "flag-token", "--password", "flag-password", "--user", "flag-user"})
+	DebugSetting.SetOn(b, true)
+	out := stdout(t, func() {
+		b.UpdateChangedFlags()
+		b.Apply()
+		b.ApplyFromFlags(pflags)
+		b.PushFlags(pflags)
+		b.PopFlags()
+		b.Set("api.token", "set-token")
+		for _, f := range []string{"token", "password", "user"} {
+			os.Stdout.WriteString(flagString(pflags.Lookup(f), false))
+		}
+	})
+	DebugSetting.SetOn(b, false)
+	out += b.Explain("api.token").String() + b.Explain("db.password").String()
+	for _, s := range []string{"file-token", "default-token", "flag-token", "flag-password", "set-token"} {
+		if strings.Contains(out, s) {
+			t.Errorf("Secret %q printed:\n%s", s, out)
+		}
+	}
+	for _, s := range []string{Redacted, "flag-user"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected %q in output:\n%s", s, out)
+		}
+	}
+	if v := b.Viper().GetString("api.token"); v != "set-token" {
+		t.Errorf("Redaction changed the value. Got: %#v, Expected: %#v", v, "set-token")
+	}
+
+	// A flag set made again, as a REPL does, has no annotations, but its flags are bound by name.
+	again := pflag.NewFlagSet("Secrets", pflag.ContinueOnError)
+	again.String("token", "", "")
+	again.Parse([]string{"--token", "again-token"})
+	DebugSetting.SetOn(b, true)
+	out = stdout(t, func() { b.ApplyFromFlags(again) })
+	DebugSetting.SetOn(b, false)
+	if strings.Contains(out, "again-token") || !strings.Contains(out, Redacted) {
+		t.Errorf("Secret printed from a flag set made again:\n%s", out)
+	}
+
+	// The journal doesn't have the value, and it isn't replayed.
+	data, err := ioutil.ReadFile(journal)
+	if err != nil {
+		t.Fatalf("Unexpected error reading the journal: %v", err)
+	}
+	if strings.Contains(string(data), "set-token") || !strings.Contains(string(data), Redacted) {
+		t.Errorf("Secret not redacted in the journal:\n%s", data)
+	}
+	entries, err := ReadJournal(journal)
+	if err != nil {
+		t.Fatalf("Unexpected error from ReadJournal: %v", err)
+	}
+	b2 := NewBinder(viper.New())
+	b2.MarkSecret("api.token")
+	if err := b2.Replay(entries); err != nil {
+		t.Fatalf("Unexpected error from Replay: %v", err)
+	}
+	if b2.Viper().IsSet("api.token") {
+		t.Errorf("Redacted value replayed: %#v", b2.Viper().Get("api.token"))
+	}
+}
+
+func TestSecretResolvers(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	secretFile := writeConfig(t, dir, "token", "from-file\n")
+	file := writeConfig(t, dir, "app.yaml", "plain: env:NOT_A_SECRET\nsecrets:\n  file: file://"+secretFile+
+		"\n  env: env:SECRETTEST_TOKEN\n  cmd: cmd:echo from-cmd\n  unset: env:SECRETTEST_UNSET\n  custom: vault:a/b\n  plain: not-a-ref\n")
+	defer setEnv(t, map[string]string{"SECRETTEST_TOKEN": "from-env"})()
+
+	if _, _, ok := resolverFor("cmd:echo from-cmd"); ok {
+		t.Errorf("cmd: shouldn't be resolved unless it's registered.")
+	}
+	RegisterResolver("cmd:", CmdResolver)
+	defer UnregisterResolver("cmd:")
+	calls := 0
+	RegisterResolver("vault:", ResolverFunc(func(ref string) (string, error) {
+		calls++
+		return "vault-" + ref, nil
+	}))
+	defer UnregisterResolver("vault:")
+
+	b := NewBinder(viper.New())
+	if err := b.Init(WithConfigFile(file), WithoutEnv()); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+
+	type tc struct {
+		key, e string
+		err    bool
+	}
+	cases := []tc{
+		{key: "secrets.file", e: "from-file"},
+		{key: "secrets.env", e: "from-env"},
+		{key: "secrets.cmd", e: "from-cmd"},
+		{key: "secrets.custom", e: "vault-a/b"},
+		{key: "secrets.plain", e: "not-a-ref"},
+		{key: "secrets.unset", err: true},
+	}
+	for _, c := range cases {
+		v, err := b.SecretValue(c.key)
+		if c.err {
+			if err == nil {
+				t.Errorf("Expected an error resolving %q, got: %#v", c.key, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error resolving %q: %v", c.key, err)
+		}
+		if v != c.e {
+			t.Errorf("Wrong value for %q. Got: %#v, Expected: %#v", c.key, v, c.e)
+		}
+	}
+	if v := b.Viper().GetString("secrets.file"); v != "file://"+secretFile {
+		t.Errorf("Viper should keep the reference. Got: %#v", v)
+	}
+
+	// Resolved once, until forgotten or the config is read again.
+	writeConfig(t, dir, "token", "rotated\n")
+	b.SecretValue("secrets.custom")
+	if v, _ := b.SecretValue("secrets.file"); v != "from-file" || calls != 1 {
+		t.Errorf("Secrets should be cached. Got: %#v after %d calls", v, calls)
+	}
+	b.ForgetSecrets()
+	if v, _ := b.SecretValue("secrets.file"); v != "rotated" {
+		t.Errorf("Wrong value after ForgetSecrets. Got: %#v, Expected: %#v", v, "rotated")
+	}
+	writeConfig(t, dir, "token", "reloaded\n")
+	if err := b.Reload(); err != nil {
+		t.Fatalf("Unexpected error from Reload: %v", err)
+	}
+	if v, _ := b.SecretValue("secrets.file"); v != "reloaded" {
+		t.Errorf("Wrong value after Reload. Got: %#v, Expected: %#v", v, "reloaded")
+	}
+
+	// Only secret Keys are resolved by Get.
+	if v := testSecretToken.GetFrom(b); v != "from-env" {
+		t.Errorf("Wrong value from a secret Key. Got: %#v, Expected: %#v", v, "from-env")
+	}
+	if v := testNotSecret.GetFrom(b); v != "env:NOT_A_SECRET" {
+		t.Errorf("Wrong value from a Key that isn't secret. Got: %#v, Expected: %#v", v, "env:NOT_A_SECRET")
+	}
+	if !b.IsSecret("secrets.env") {
+		t.Errorf("Expected the secret Key to be secret in the Binder.")
+	}
+
+	// Errors don't show the secret, and can be looked into.
+	RegisterResolver("fail:", ResolverFunc(func(ref string) (string, error) { return "", os.ErrNotExist }))
+	defer UnregisterResolver("fail:")
+	b.Set("secrets.failing", "fail:x")
+	if _, err := b.SecretValue("secrets.failing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Wrong error from a failing Resolver. Got: %v", err)
+	}
+}
+
+func TestSecretErrors(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := writeConfig(t, dir, "app.yaml", "screen: hunter-screen\nname: Hunter Name\nsreen: hunter-sreen\nserver:\n  hosts: [a, 1]\n")
+	s, _ := ParseSchema([]byte(testSchema))
+	defer setEnv(t, map[string]string{"SECRETERR_SERVER_PORT": "hunter-env"})()
+
+	b := NewBinder(viper.New())
+	b.MarkSecret("screen", "name", "sreen", "server", "token")
+	pflags := pflag.NewFlagSet("SecretErrors", pflag.ContinueOnError)
+	pflags.Int("port", 0, "")
+	pflags.String("token", "", "")
+	pflags.String("api-token", "", "")
+	b.Bind("server.port", pflags.Lookup("port"))
+	b.Bind("token", pflags.Lookup("token"))
+	b.Bind("token", pflags.Lookup("api-token"))
+
+	errs := []error{b.Init(WithConfigFile(file), WithEnvPrefix("SECRETERR"), WithSchema(s))}
+	pflags.Parse([]string{"--token", "hunter2", "--api-token", "hunter3"})
+	errs = append(errs, b.ApplyFromFlags(pflags), b.Set("server.port", "hunter-set"), b.Set("name", "Hunter Set"))
+	b2 := NewBinder(viper.New())
+	b2.MarkSecret("server.port")
+	b2.Bind("server.port", pflags.Lookup("port"))
+	errs = append(errs, b2.Init(WithConfigFile(writeConfig(t, dir, "env.yaml", "")), WithEnvPrefix("SECRETERR")))
+	var ce *FlagConflictError
+	if !errors.As(errs[1], &ce) {
+		t.Fatalf("Expected a FlagConflictError. Got: %#v", errs[1])
+	}
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("Expected an error %d.", i)
+			continue
+		}
+		if strings.Contains(strings.ToLower(err.Error()), "hunter") || !strings.Contains(err.Error(), Redacted) {
+			t.Errorf("Secret in error %d: %v", i, err)
+		}
+		for _, se := range schemaErrors(err) {
+			if se.Value != Redacted {
+				t.Errorf("Secret in SchemaError value: %#v", se)
+			}
+		}
+	}
+	for _, v := range ce.Values {
+		if v != Redacted {
+			t.Errorf("Secret in FlagConflictError values: %#v", ce.Values)
+		}
+	}
+}
+
+func TestSecretStruct(t *testing.T) {
+	type config struct {
+		Token string `flag:"struct-token" secret:"true"`
+		User  string `flag:"struct-user"`
+	}
+	b := NewBinder(viper.New())
+	pflags := pflag.NewFlagSet("SecretStruct", pflag.ContinueOnError)
+	if err := b.BindStruct(pflags, &config{}); err != nil {
+		t.Fatalf("Unexpected error from BindStruct: %v", err)
+	}
+	if !b.IsSecret("token") || !isSecretFlag(pflags.Lookup("struct-token")) {
+		t.Errorf("Expected the token key and flag to be secret.")
+	}
+	if b.IsSecret("user") || isSecretFlag(pflags.Lookup("struct-user")) {
+		t.Errorf("Expected the user key and flag not to be secret.")
+	}
+}
@@ -0,0 +1,416 @@
+package vconfig
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+)
+
+/*
+* Binding a struct.
+*
+* Rather than a Bind and a viper.Get for each key, the keys can be
+* declared as the fields of a struct:
+*
+*	type Config struct {
+*		File   string `vconfig:"filename" flag:"file,f" default:"x" usage:"The file to read." env:"APP_FILE"`
+*		Server struct {
+*			Port int `flag:"port" default:"8080"`
+*		}
+*	}
+*
+* BindStruct makes a flag for each field with a flag tag, binds it to the
+* field's key and sets the defaults, and FillStruct copies the values
+* viper ends up with back into the struct, e.g. after ApplyFromFlags.
+*
+* The key is the vconfig tag, or the lower case field name, and
+* nested structs give dotted keys: server.port above. Embedded structs
+* without a tag share the key space of the struct they're in, and a
+* field tagged vconfig:"-" is left alone. A field tagged secret:"true"
+* has a secret key, see secret.go.
+ */
+
+// structField is a field with a key, found by walking a struct.
+type structField struct {
+	path   string // Go name, e.g. Server.Port.
+	index  []int
+	typ    reflect.Type
+	key    string
+	flag   string
+	short  string
+	def    string
+	usage  string
+	env    string
+	hasDef bool
+	secret bool
+}
+
+var valueType = reflect.TypeOf((*pflag.Value)(nil)).Elem()
+
+// fieldTypes are the pflag types for field types that aren't known by their kind.
+var fieldTypes = map[reflect.Type]string{
+	durType:                             "duration",
+	ipType:                              "ip",
+	ipMaskType:                          "ipMask",
+	ipNetType:                           "ipNet",
+	reflect.TypeOf([]string{}):          "stringSlice",
+	reflect.TypeOf([]bool{}):            "boolSlice",
+	reflect.TypeOf([]int{}):             "intSlice",
+	reflect.TypeOf([]int32{}):           "int32Slice",
+	reflect.TypeOf([]int64{}):           "int64Slice",
+	reflect.TypeOf([]uint{}):            "uintSlice",
+	reflect.TypeOf([]float32{}):         "float32Slice",
+	reflect.TypeOf([]float64{}):         "float64Slice",
+	reflect.TypeOf([]time.Duration{}):   "durationSlice",
+	reflect.TypeOf([]net.IP{}):          "ipSlice",
+	reflect.TypeOf(map[string]string{}): "stringToString",
+	reflect.TypeOf(map[string]int{}):    "stringToInt",
+	reflect.TypeOf(map[string]int64{}):  "stringToInt64",
+}
+
+// newFlags make a flag of each pflag type, with its zero value.
+var newFlags = map[string]func(fs *pflag.FlagSet, name, short, usage string){
+	"string":  func(fs *pflag.FlagSet, n, s, u string) { fs.StringP(n, s, "", u) },
+	"bool":    func(fs *pflag.FlagSet, n, s, u string) { fs.BoolP(n, s, false, u) },
+	"int":     func(fs *pflag.FlagSet, n, s, u string) { fs.IntP(n, s, 0, u) },
+	"int8":    func(fs *pflag.FlagSet, n, s, u string) { fs.Int8P(n, s, 0, u) },
+	"int16":   func(fs *pflag.FlagSet, n, s, u string) { fs.Int16P(n, s, 0, u) },
+	"int32":   func(fs *pflag.FlagSet, n, s, u string) { fs.Int32P(n, s, 0, u) },
+	"int64":   func(fs *pflag.FlagSet, n, s, u string) { fs.Int64P(n, s, 0, u) },
+	"uint":    func(fs *pflag.FlagSet, n, s, u string) { fs.UintP(n, s, 0, u) },
+	"uint8":   func(fs *pflag.FlagSet, n, s, u string) { fs.Uint8P(n, s, 0, u) },
+	"uint16":  func(fs *pflag.FlagSet, n, s, u string) { fs.Uint16P(n, s, 0, u) },
+	"uint32":  func(fs *pflag.FlagSet, n, s, u string) { fs.Uint32P(n, s, 0, u) },
+	"uint64":  func(fs *pflag.FlagSet, n, s, u string) { fs.Uint64P(n, s, 0, u) },
+	"float32": func(fs *pflag.FlagSet, n, s, u string) { fs.Float32P(n, s, 0, u) },
+	"float64": func(fs *pflag.FlagSet, n, s, u string) { fs.Float64P(n, s, 0, u) },
+
+	"duration": func(fs *pflag.FlagSet, n, s, u string) { fs.DurationP(n, s, 0, u) },
+	"ip":       func(fs *pflag.FlagSet, n, s, u string) { fs.IPP(n, s, nil, u) },
+	"ipMask":   func(fs *pflag.FlagSet, n, s, u string) { fs.IPMaskP(n, s, nil, u) },
+	"ipNet":    func(fs *pflag.FlagSet, n, s, u string) { fs.IPNetP(n, s, net.IPNet{}, u) },
+
+	"stringSlice":   func(fs *pflag.FlagSet, n, s, u string) { fs.StringSliceP(n, s, nil, u) },
+	"boolSlice":     func(fs *pflag.FlagSet, n, s, u string) { fs.BoolSliceP(n, s, nil, u) },
+	"intSlice":      func(fs *pflag.FlagSet, n, s, u string) { fs.IntSliceP(n, s, nil, u) },
+	"int32Slice":    func(fs *pflag.FlagSet, n, s, u string) { fs.Int32SliceP(n, s, nil, u) },
+	"int64Slice":    func(fs *pflag.FlagSet, n, s, u string) { fs.Int64SliceP(n, s, nil, u) },
+	"uintSlice":     func(fs *pflag.FlagSet, n, s, u string) { fs.UintSliceP(n, s, nil, u) },
+	"float32Slice":  func(fs *pflag.FlagSet, n, s, u string) { fs.Float32SliceP(n, s, nil, u) },
+	"float64Slice":  func(fs *pflag.FlagSet, n, s, u string) { fs.Float64SliceP(n, s, nil, u) },
+	"durationSlice": func(fs *pflag.FlagSet, n, s, u string) { fs.DurationSliceP(n, s, nil, u) },
+	"ipSlice":       func(fs *pflag.FlagSet, n, s, u string) { fs.IPSliceP(n, s, nil, u) },
+
+	"stringToString": func(fs *pflag.FlagSet, n, s, u string) { fs.StringToStringP(n, s, nil, u) },
+	"stringToInt":    func(fs *pflag.FlagSet, n, s, u string) { fs.StringToIntP(n, s, nil, u) },
+	"stringToInt64":  func(fs *pflag.FlagSet, n, s, u string) { fs.StringToInt64P(n, s, nil, u) },
+}
+
+// flagType returns the pflag type for a field type.
+func flagType(t reflect.Type) (string, bool) {
+	if ft, ok := fieldTypes[t]; ok {
+		return ft, true
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return t.Kind().String(), true
+	}
+	return "", false
+}
+
+// isValue is true for field types whose pointer is a pflag.Value,
+// which are used for their flags as they are.
+func isValue(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(valueType)
+}
+
+// structFields walks the struct type t, returning its fields with keys.
+func structFields(t reflect.Type, prefix, path string, index []int) (fields []structField, err error) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, ok := sf.Tag.Lookup("vconfig")
+		if name == "-" || (sf.PkgPath != "" && !sf.Anonymous) {
+			continue
+		}
+		idx := append(append([]int(nil), index...), i)
+		key := prefix
+		if !(sf.Anonymous && !ok) {
+			if name == "" {
+				name = strings.ToLower(sf.Name)
+			}
+			key = joinKey(prefix, name)
+		}
+		fpath := joinKey(path, sf.Name)
+
+		if _, known := flagType(sf.Type); !known && !isValue(sf.Type) {
+			if sf.Type.Kind() != reflect.Struct {
+				return nil, fmt.Errorf("field %s: can't bind a %s", fpath, sf.Type)
+			}
+			nested, err := structFields(sf.Type, key, fpath, idx)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, nested...)
+			continue
+		}
+		if sf.PkgPath != "" { // An unexported embedded non-struct.
+			continue
+		}
+
+		f := structField{path: fpath, index: idx, typ: sf.Type, key: key,
+			usage: sf.Tag.Get("usage"), env: sf.Tag.Get("env")}
+		f.def, f.hasDef = sf.Tag.Lookup("default")
+		f.secret, _ = strconv.ParseBool(sf.Tag.Get("secret"))
+		if fl := sf.Tag.Get("flag"); fl != "" {
+			parts := strings.SplitN(fl, ",", 2)
+			f.flag = parts[0]
+			if len(parts) == 2 {
+				f.short = parts[1]
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// structType checks that s is a pointer to a struct.
+func structType(s interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(s)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("need a pointer to a struct, not %T", s)
+	}
+	return rv.Elem(), nil
+}
+
+// newFlag makes the flag for a field, with its default.
+func (f structField) newFlag() (*pflag.Flag, error) {
+	fs := pflag.NewFlagSet("", pflag.ContinueOnError)
+	if isValue(f.typ) {
+		fs.VarP(reflect.New(f.typ).Interface().(pflag.Value), f.flag, f.short, f.usage)
+	} else {
+		ft, _ := flagType(f.typ)
+		newFlags[ft](fs, f.flag, f.short, f.usage)
+	}
+	pf := fs.Lookup(f.flag)
+	if f.hasDef {
+		if err := pf.Value.Set(f.def); err != nil {
+			return nil, &ConvertError{Flag: f.flag, Type: pf.Value.Type(), Value: f.def, Err: err}
+		}
+		pf.DefValue = pf.Value.String()
+	}
+	return pf, nil
+}
+
+// defValue converts the default tag to the go value for the field.
+func (f structField) defValue() (interface{}, error) {
+	if isValue(f.typ) {
+		pv := reflect.New(f.typ).Interface().(pflag.Value)
+		if err := pv.Set(f.def); err != nil {
+			return nil, &ConvertError{Type: pv.Type(), Value: f.def, Err: err}
+		}
+		return pv.String(), nil
+	}
+	ft, _ := flagType(f.typ)
+	return stringValue(f.def, ft)
+}
+
+// BindStruct adds a flag to pflags for each field of the struct s points to that has a flag tag,
+// binds it to the field's key, and sets the defaults and environment variables from the tags.
+// Nothing is changed if there's an error, e.g. a flag that's already in pflags.
+func BindStruct(pflags *pflag.FlagSet, s interface{}) error {
+	return std.BindStruct(pflags, s)
+}
+
+// BindStruct binds the fields of the struct s points to in this Binder.
+// See the package level BindStruct.
+func (b *Binder) BindStruct(pflags *pflag.FlagSet, s interface{}) error {
+	if b.Debug() {
+		pef()
+		defer pxf()
+	}
+	rv, err := structType(s)
+	if err != nil {
+		return err
+	}
+	fields, err := structFields(rv.Type(), "", "", nil)
+	if err != nil {
+		return err
+	}
+
+	// Make everything first, so an error leaves nothing behind.
+	flags := make([]*pflag.Flag, len(fields))
+	defs := make([]interface{}, len(fields))
+	names := make(map[string]bool)
+	for i, f := range fields {
+		if f.flag != "" {
+			if pflags.Lookup(f.flag) != nil || names[f.flag] {
+				return fmt.Errorf("field %s: flag --%s is already defined", f.path, f.flag)
+			}
+			names[f.flag] = true
+			if flags[i], err = f.newFlag(); err != nil {
+				return fmt.Errorf("field %s: %w", f.path, err)
+			}
+		}
+		if f.hasDef {
+			if defs[i], err = f.defValue(); err != nil {
+				return fmt.Errorf("field %s: %w", f.path, err)
+			}
+		}
+	}
+
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.refresh()
+	var errs errorList
+	for i, f := range fields {
+		if f.secret {
+			b.markSecret(f.key)
+		}
+		if flags[i] != nil {
+			pflags.AddFlag(flags[i])
+			b.bind(f.key, flags[i])
+		}
+		if f.hasDef {
+			b.setDefault(f.key, defs[i])
+		}
+		if f.env != "" {
+			errs = errs.add(b.bindEnv(f.key, f.env))
+		}
+	}
+	return errs.err()
+}
+
+// FillStruct sets the fields of the struct s points to from the values for their keys.
+// Fields for keys without a value get their zero value.
+func FillStruct(s interface{}) error {
+	return std.FillStruct(s)
+}
+
+// FillStruct sets the fields of the struct s points to from this Binder's values.
+// See the package level FillStruct.
+func (b *Binder) FillStruct(s interface{}) error {
+	if b.Debug() {
+		pef()
+		defer pxf()
+	}
+	rv, err := structType(s)
+	if err != nil {
+		return err
+	}
+	fields, err := structFields(rv.Type(), "", "", nil)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var errs errorList
+	for _, f := range fields {
+		fv, err := fieldValue(b.get(f.key), f.typ)
+		if err != nil {
+			errs = errs.add(fmt.Errorf("field %s from %q: %w", f.path, f.key, err))
+			continue
+		}
+		rv.FieldByIndex(f.index).Set(fv)
+	}
+	return errs.err()
+}
+
+// fieldValue converts a value from viper to the type of a field.
+// Strings are parsed as they would be for a flag, and everything else is cast.
+func fieldValue(v interface{}, t reflect.Type) (reflect.Value, error) {
+	if v == nil {
+		return reflect.Zero(t), nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(t) {
+		return rv, nil
+	}
+	if s, ok := v.(string); ok {
+		if isValue(t) {
+			pv := reflect.New(t)
+			err := pv.Interface().(pflag.Value).Set(s)
+			return pv.Elem(), err
+		}
+		if ft, ok := flagType(t); ok && t.Kind() != reflect.String {
+			cv, err := stringValue(s, ft)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(cv).Convert(t), nil
+		}
+	}
+
+	var cv interface{}
+	var err error
+	switch t.Kind() {
+	case reflect.Bool:
+		cv, err = cast.ToBoolE(v)
+	case reflect.String:
+		cv, err = cast.ToStringE(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = cast.ToInt64E(v); err == nil && reflect.Zero(t).OverflowInt(i) {
+			err = fmt.Errorf("%d overflows %s", i, t)
+		}
+		cv = i
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if u, err = cast.ToUint64E(v); err == nil && reflect.Zero(t).OverflowUint(u) {
+			err = fmt.Errorf("%d overflows %s", u, t)
+		}
+		cv = u
+	case reflect.Float32, reflect.Float64:
+		cv, err = cast.ToFloat64E(v)
+	case reflect.Slice:
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return reflect.Value{}, fmt.Errorf("can't make a %s from %T", t, v)
+		}
+		out := reflect.MakeSlice(t, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			ev, err := fieldValue(rv.Index(i).Interface(), t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out = reflect.Append(out, ev)
+		}
+		return out, nil
+	case reflect.Map:
+		m, err := cast.ToStringMapE(v)
+		if err != nil || t.Key().Kind() != reflect.String {
+			return reflect.Value{}, fmt.Errorf("can't make a %s from %T", t, v)
+		}
+		out := reflect.MakeMapWithSize(t, len(m))
+		for k, mv := range m {
+			ev, err := fieldValue(mv, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), ev)
+		}
+		return out, nil
+	default:
+		return reflect.Value{}, fmt.Errorf("can't make a %s from %T", t, v)
+	}
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(cv).Convert(t), nil
+}
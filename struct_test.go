@@ -0,0 +1,137 @@
+package vconfig
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type testCommon struct {
+	Verbose bool `flag:"verbose,v" usage:"Say more."`
+}
+
+type testStructConfig struct {
+	testCommon
+	File    string        `vconfig:"filename" flag:"file,f" default:"x.txt" usage:"The file to read." env:"VCONFIG_TEST_FILE"`
+	Timeout time.Duration `flag:"timeout" default:"1s"`
+	Tags    []string      `flag:"tag"`
+	Server  struct {
+		Host string `default:"localhost"`
+		Port int    `flag:"port" default:"8080"`
+	}
+	Labels  map[string]string
+	Ignored string `vconfig:"-" flag:"ignored"`
+	hidden  string
+}
+
+func TestBindStruct(t *testing.T) {
+	b := NewBinder(viper.New())
+	v := b.Viper()
+	pflags := pflag.NewFlagSet("Struct", pflag.ContinueOnError)
+	var c testStructConfig
+	os.Setenv("VCONFIG_TEST_FILE", "env.txt")
+	defer os.Unsetenv("VCONFIG_TEST_FILE")
+	if err := b.BindStruct(pflags, &c); err != nil {
+		t.Fatalf("Unexpected error from BindStruct: %v", err)
+	}
+
+	// Flags.
+	type ftc struct {
+		name, short, def, usage, key string
+	}
+	for _, e := range []ftc{
+		{"verbose", "v", "false", "Say more.", "verbose"},
+		{"file", "f", "x.txt", "The file to read.", "filename"},
+		{"timeout", "", "1s", "", "timeout"},
+		{"tag", "", "[]", "", "tags"},
+		{"port", "", "8080", "", "server.port"},
+	} {
+		pf := pflags.Lookup(e.name)
+		if pf == nil {
+			t.Errorf("No flag %q", e.name)
+			continue
+		}
+		if pf.Shorthand != e.short || pf.DefValue != e.def || pf.Usage != e.usage {
+			t.Errorf("Wrong flag %q. Got: %q %q %q, Expected: %q %q %q", e.name, pf.Shorthand, pf.DefValue, pf.Usage, e.short, e.def, e.usage)
+		}
+		if bf := b.bbm[e.key]; bf == nil || bf.Flag != pf {
+			t.Errorf("Flag %q isn't bound to %q", e.name, e.key)
+		}
+	}
+	if pflags.Lookup("ignored") != nil {
+		t.Errorf("Made a flag for an ignored field")
+	}
+
+	// Defaults, the environment and flags all end up in the struct.
+	v.Set("labels", map[string]interface{}{"a": "b"})
+	if err := pflags.Parse([]string{"-v", "--port", "9090", "--tag", "a,b"}); err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+	if err := b.ApplyFromFlags(pflags); err != nil {
+		t.Fatalf("Unexpected error from ApplyFromFlags: %v", err)
+	}
+	c.Ignored, c.hidden = "left", "alone"
+	if err := b.FillStruct(&c); err != nil {
+		t.Fatalf("Unexpected error from FillStruct: %v", err)
+	}
+	expected := testStructConfig{testCommon: testCommon{Verbose: true}, File: "env.txt", Timeout: time.Second,
+		Tags: []string{"a", "b"}, Labels: map[string]string{"a": "b"}, Ignored: "left", hidden: "alone"}
+	expected.Server.Host = "localhost"
+	expected.Server.Port = 9090
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("Wrong struct.\nGot: %#v\nExpected: %#v", c, expected)
+	}
+
+	// Values from a file are converted.
+	v.Set("timeout", "2m")
+	v.Set("server.port", "7070")
+	v.Set("tags", []interface{}{"x"})
+	if err := b.FillStruct(&c); err != nil {
+		t.Fatalf("Unexpected error from FillStruct: %v", err)
+	}
+	if c.Timeout != 2*time.Minute || c.Server.Port != 7070 || !reflect.DeepEqual(c.Tags, []string{"x"}) {
+		t.Errorf("Wrong converted values. Got: %v %v %#v", c.Timeout, c.Server.Port, c.Tags)
+	}
+	v.Set("server.port", "seventy")
+	if err := b.FillStruct(&c); err == nil {
+		t.Errorf("Expected an error filling from a bad value")
+	}
+}
+
+func TestBindStructErrors(t *testing.T) {
+	type tc struct {
+		name string
+		s    interface{}
+	}
+	cases := []tc{
+		{"not a pointer", testStructConfig{}},
+		{"bad default", &struct {
+			N int `flag:"n" default:"x"`
+		}{}},
+		{"duplicate flag", &struct {
+			A string `flag:"dup"`
+			B string `flag:"dup"`
+		}{}},
+		{"existing flag", &struct {
+			A string `flag:"existing"`
+		}{}},
+		{"unsupported type", &struct {
+			C chan int
+		}{}},
+	}
+	for _, c := range cases {
+		b := NewBinder(viper.New())
+		pflags := pflag.NewFlagSet("Errors", pflag.ContinueOnError)
+		pflags.String("existing", "", "")
+		if err := b.BindStruct(pflags, c.s); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+		if n := len(b.GetBindFlags()); n != 0 {
+			t.Errorf("%s: bindings left behind. Got: %d, Expected: 0", c.name, n)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package vconfig
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,41 +10,42 @@ import (
 
 	"github.com/juju/ansiterm"
 	"github.com/spf13/pflag"
-	"github.com/spf13/viper"
 )
 
 // Debug returns whether debug mode is set.
+// This is safe to call from any goroutine and doesn't wait for a lock.
 func Debug() bool {
-	return viper.GetBool(DebugKey)
+	return std.Debug()
 }
 
 // SetDebug allows you to turn on or off the debug mode.
 func SetDebug(b bool) {
-	Set(DebugKey, b)
+	DebugSetting.Set(b)
 }
 
 // ToggleDebug toggles the flag and returns the new value.
 func ToggleDebug() bool {
-	Set(DebugKey, !viper.GetBool(DebugKey))
-	return Debug()
+	return DebugSetting.Toggle()
 }
 
 // Verbose returs whether verbose mode is set.
+// This is safe to call from any goroutine and doesn't wait for a lock.
 func Verbose() bool {
-	return viper.GetBool(VerboseKey)
+	return std.Verbose()
 }
 
 // ToggleVerbose toggles the flag and returns the new value.
 func ToggleVerbose() bool {
-	Set(VerboseKey, !viper.GetBool(VerboseKey))
-	return Verbose()
+	return VerboseSetting.Toggle()
 }
 
-func flagString(pf *pflag.Flag) string {
+// flagString prints pf in a table. Its values are Redacted if secret,
+// or if pf is marked secret.
+func flagString(pf *pflag.Flag, secret bool) string {
 	var b strings.Builder
 	w := ansiterm.NewTabWriter(&b, 4, 4, 2, ' ', 0)
 	fmt.Fprintf(w, flagHeader()+"\n")
-	fmt.Fprintf(w, flagEntry(pf)+"\n")
+	fmt.Fprintf(w, flagEntry(pf, secret)+"\n")
 	w.Flush()
 	return b.String()
 }
@@ -52,14 +54,26 @@ func flagHeader() string {
 	return "Name\tShort\tValue\tType\tDefValue\tChanged"
 }
 
-func flagEntry(f *pflag.Flag) string {
+func flagEntry(f *pflag.Flag, secret bool) string {
 	if f != nil {
+		def := f.DefValue
+		if (secret || isSecretFlag(f)) && def != "" {
+			def = Redacted
+		}
 		return fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%t",
-			f.Name, f.Shorthand, f.Value.String(), f.Value.Type(), f.DefValue, f.Changed)
+			f.Name, f.Shorthand, flagText(f, secret), f.Value.Type(), def, f.Changed)
 	}
 	return "<No Flag>\t-\t-\t-\t-\t-"
 }
 
+// flagText is the flag's value for printing, Redacted if secret or f is marked secret.
+func flagText(f *pflag.Flag, secret bool) string {
+	if secret || isSecretFlag(f) {
+		return Redacted
+	}
+	return f.Value.String()
+}
+
 // pef is an entry tracing printout to stdout. It will print the word Enter, a funciton name, file name and line number.
 // It is designed to be used as the first line of a function, perhaps bracketed by a debug check.
 func pef() {
@@ -94,3 +108,60 @@ func loc(d int) (fnc, file string, line int) {
 	}
 	return fnc, file, line
 }
+
+// errorList collects the errors from work done over a number of items,
+// e.g. each of the flags in a flag set.
+type errorList []error
+
+func (el errorList) add(err error) errorList {
+	if err != nil {
+		el = append(el, err)
+	}
+	return el
+}
+
+// err returns nil for an empty list, the only error for a list of one
+// and the list otherwise.
+func (el errorList) err() error {
+	switch len(el) {
+	case 0:
+		return nil
+	case 1:
+		return el[0]
+	}
+	return el
+}
+
+func (el errorList) Error() string {
+	ss := make([]string, len(el))
+	for i, e := range el {
+		ss[i] = e.Error()
+	}
+	return strings.Join(ss, "; ")
+}
+
+// Unwrap returns the errors, which errors.Is and errors.As look at from Go 1.20.
+func (el errorList) Unwrap() []error {
+	return el
+}
+
+// Is reports whether any of the errors is target, so errors.Is
+// looks at each of them before Go 1.20 too.
+func (el errorList) Is(target error) bool {
+	for _, e := range el {
+		if errors.Is(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target, as errors.As does.
+func (el errorList) As(target interface{}) bool {
+	for _, e := range el {
+		if errors.As(e, target) {
+			return true
+		}
+	}
+	return false
+}
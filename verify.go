@@ -0,0 +1,155 @@
+package vconfig
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/pflag"
+)
+
+/*
+* Checking the bindings.
+*
+* The bindings are kept in three maps: by bind key, by flag name and by
+* the flag itself. Bind keeps them in step, but a program that wires up
+* a large command tree wants to know for sure, and to hear about flags
+* that were quietly moved from one key to another. BindE refuses to move
+* a flag, and Verify walks the maps reporting anything that doesn't agree,
+* e.g. from a test run in CI after the commands are built.
+ */
+
+// BindError is returned by BindE when a flag can't be bound to a key.
+type BindError struct {
+	Key     string
+	Flag    string
+	BoundTo string // The key the flag is already bound to.
+}
+
+func (e *BindError) Error() string {
+	switch {
+	case e.Flag == "":
+		return fmt.Sprintf("no flag to bind to %q", e.Key)
+	case e.Key == "":
+		return fmt.Sprintf("no key to bind --%s to", e.Flag)
+	}
+	return fmt.Sprintf("flag --%s is already bound to %q, can't bind it to %q", e.Flag, e.BoundTo, e.Key)
+}
+
+// BindingError is a problem with the bindings found by Verify.
+// Dangling is true when an entry points at a binding that has been removed,
+// and false when the entries disagree about a binding that's still there.
+type BindingError struct {
+	Key      string
+	Flag     string // Empty for problems with the binding itself.
+	Dangling bool
+	Problem  string
+}
+
+func (e *BindingError) Error() string {
+	kind := "mismatched"
+	if e.Dangling {
+		kind = "dangling"
+	}
+	if e.Flag == "" {
+		return fmt.Sprintf("%s binding %q: %s", kind, e.Key, e.Problem)
+	}
+	return fmt.Sprintf("%s binding %q for flag --%s: %s", kind, e.Key, e.Flag, e.Problem)
+}
+
+// Verify checks that the bindings agree with each other,
+// returning a BindingError for each problem it finds.
+func Verify() error {
+	return std.Verify()
+}
+
+// Verify checks that the bindings in this Binder agree with each other.
+// See the package level Verify.
+func (b *Binder) Verify() error {
+	if b.Debug() {
+		pef()
+		defer pxf()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var errs errorList
+	report := func(bf *BindFlag, flag string, dangling bool, format string, args ...interface{}) {
+		errs = errs.add(&BindingError{Key: bf.BindKey, Flag: flag, Dangling: dangling, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	// By key.
+	for _, bk := range b.sortedBindKeys() {
+		bf := b.bbm[bk]
+		if bf.BindKey != bk {
+			report(bf, "", false, "it's filed under %q", bk)
+		}
+		if len(bf.flags) == 0 {
+			report(bf, "", true, "it has no flags")
+		} else if !hasFlag(bf, bf.Flag) {
+			report(bf, bf.Flag.Name, false, "the flag isn't one of the binding's flags")
+		}
+		for _, f := range bf.flags {
+			switch other := b.bpm[f]; {
+			case other == nil:
+				report(bf, f.Name, false, "the flag isn't in the flag map")
+			case other != bf:
+				report(bf, f.Name, false, "the flag map has it bound to %q", other.BindKey)
+			}
+			if b.bfm[f.Name] == nil {
+				report(bf, f.Name, false, "the flag name isn't in the flag name map")
+			}
+		}
+	}
+
+	// By flag.
+	var pfs []*pflag.Flag
+	for f := range b.bpm {
+		pfs = append(pfs, f)
+	}
+	sort.Slice(pfs, func(i, j int) bool {
+		ki, kj := b.bpm[pfs[i]].BindKey, b.bpm[pfs[j]].BindKey
+		if ki != kj {
+			return ki < kj
+		}
+		return pfs[i].Name < pfs[j].Name
+	})
+	for _, f := range pfs {
+		bf := b.bpm[f]
+		if b.bbm[bf.BindKey] != bf {
+			report(bf, f.Name, true, "the key isn't bound")
+		} else if !hasFlag(bf, f) {
+			report(bf, f.Name, false, "the flag isn't one of the binding's flags")
+		}
+	}
+
+	// By flag name.
+	var names []string
+	for name := range b.bfm {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		bf := b.bfm[name]
+		if b.bbm[bf.BindKey] != bf {
+			report(bf, name, true, "the key isn't bound")
+			continue
+		}
+		named := false
+		for _, f := range bf.flags {
+			named = named || f.Name == name
+		}
+		if !named {
+			report(bf, name, false, "the binding has no flag with that name")
+		}
+	}
+	return errs.err()
+}
+
+func hasFlag(bf *BindFlag, f *pflag.Flag) bool {
+	for _, bff := range bf.flags {
+		if bff == f {
+			return true
+		}
+	}
+	return false
+}
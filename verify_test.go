@@ -0,0 +1,118 @@
+package vconfig
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestBindE(t *testing.T) {
+	b := NewBinder(viper.New())
+	pflags := pflag.NewFlagSet("BindE", pflag.ContinueOnError)
+	pflags.String("file", "", "")
+	pflags.String("out", "", "")
+
+	type tc struct {
+		bk       string
+		f        *pflag.Flag
+		expected *BindError
+	}
+	cases := []tc{
+		{"filename", pflags.Lookup("file"), nil},
+		{"filename", pflags.Lookup("file"), nil}, // Binding again is fine.
+		{"filename", pflags.Lookup("out"), nil},
+		{"output", pflags.Lookup("out"), &BindError{Key: "output", Flag: "out", BoundTo: "filename"}},
+		{"output", nil, &BindError{Key: "output"}},
+		{"", pflags.Lookup("out"), &BindError{Flag: "out"}},
+	}
+	for i, c := range cases {
+		_, err := b.BindE(c.bk, c.f)
+		if c.expected == nil {
+			if err != nil {
+				t.Errorf("Case %d: unexpected error: %v", i, err)
+			}
+			continue
+		}
+		var be *BindError
+		if !errors.As(err, &be) || *be != *c.expected {
+			t.Errorf("Case %d: wrong error. Got: %#v, Expected: %#v", i, err, c.expected)
+		}
+	}
+	// The failures left the bindings alone.
+	if bf := b.bbm["filename"]; bf == nil || len(bf.flags) != 2 {
+		t.Errorf("Wrong binding for filename. Got: %#v", bf)
+	}
+	if _, ok := b.bbm["output"]; ok {
+		t.Errorf("Failed BindE made a binding for output")
+	}
+	if err := b.Verify(); err != nil {
+		t.Errorf("Unexpected error from Verify: %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	pflags := pflag.NewFlagSet("Verify", pflag.ContinueOnError)
+	pflags.String("file", "", "")
+	pflags.String("out", "", "")
+	setup := func() *Binder {
+		b := NewBinder(viper.New())
+		b.Bind("filename", pflags.Lookup("file"))
+		b.Bind("output", pflags.Lookup("out"))
+		return b
+	}
+
+	type tc struct {
+		name     string
+		breakIt  func(b *Binder)
+		expected []BindingError
+	}
+	cases := []tc{
+		{"consistent", func(b *Binder) {}, nil},
+		{"old key left behind", func(b *Binder) {
+			b.bbm["old"] = b.bbm["filename"]
+		}, []BindingError{{Key: "filename", Problem: "it's filed under \"old\""}}},
+		{"removed binding", func(b *Binder) {
+			delete(b.bbm, "output")
+		}, []BindingError{
+			{Key: "output", Flag: "out", Dangling: true, Problem: "the key isn't bound"},
+			{Key: "output", Flag: "out", Dangling: true, Problem: "the key isn't bound"},
+		}},
+		{"flag map", func(b *Binder) {
+			b.bpm[pflags.Lookup("out")] = b.bbm["filename"]
+		}, []BindingError{
+			{Key: "output", Flag: "out", Problem: "the flag map has it bound to \"filename\""},
+			{Key: "filename", Flag: "out", Problem: "the flag isn't one of the binding's flags"},
+		}},
+		{"flag name map", func(b *Binder) {
+			b.bfm["out"] = b.bbm["filename"]
+		}, []BindingError{
+			{Key: "filename", Flag: "out", Problem: "the binding has no flag with that name"},
+		}},
+	}
+	for _, c := range cases {
+		b := setup()
+		c.breakIt(b)
+		err := b.Verify()
+		var got []BindingError
+		if el, ok := err.(errorList); ok {
+			for _, e := range el {
+				got = append(got, *e.(*BindingError))
+			}
+		} else if be, ok := err.(*BindingError); ok {
+			got = append(got, *be)
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %#v", c.name, err)
+		}
+		if len(got) != len(c.expected) {
+			t.Errorf("%s: wrong errors.\nGot: %#v\nExpected: %#v", c.name, got, c.expected)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.expected[i] {
+				t.Errorf("%s: wrong error %d. Got: %#v, Expected: %#v", c.name, i, got[i], c.expected[i])
+			}
+		}
+	}
+}
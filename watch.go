@@ -0,0 +1,271 @@
+package vconfig
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+/*
+* Watching the config file(s).
+*
+* Watch reloads the config when one of the files read, or one that would
+* be read in its place, changes on disk, including new files matching an include. Editors often write a file in
+* several steps, so we wait for things to settle (WatchDelay) first.
+* After the reload Apply is run so that values from Set stay on top,
+* and the callback is told which keys have a new value.
+ */
+
+// WatchDelay is how long Watch waits after the last change to a file before reloading.
+var WatchDelay = 100 * time.Millisecond
+
+// ReloadEvent reports on a reload done by Watch.
+type ReloadEvent struct {
+	Files []string // The files that changed on disk.
+	Keys  []string // Keys whose value changed, sorted.
+	Err   error    // The reload failed and the config is as it was.
+}
+
+type watcher struct {
+	fsw  *fsnotify.Watcher
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Watch starts watching the config files read by Init, reloading when they change.
+// fn, which may be nil, is called after each reload. It is not called with the Binder locked,
+// but must not call StopWatch.
+func Watch(fn func(ReloadEvent)) error {
+	return std.Watch(fn)
+}
+
+// Watch starts watching the config files read by Init, reloading when they change.
+// See the package level Watch.
+func (b *Binder) Watch(fn func(ReloadEvent)) error {
+	if b.Debug() {
+		pef()
+		defer pxf()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.opts == nil {
+		return fmt.Errorf("watch before Init")
+	}
+	if b.watcher != nil {
+		return fmt.Errorf("already watching")
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	w := &watcher{fsw: fsw, done: make(chan struct{})}
+	files := b.watchFiles()
+	for _, d := range watchDirs(files) {
+		// Directories that don't exist can't be watched, and files
+		// there are not going to show up without one.
+		fsw.Add(d)
+	}
+	b.watcher = w
+
+	w.wg.Add(1)
+	go b.watch(w, files, fn)
+	return nil
+}
+
+// StopWatch stops watching the config files.
+func StopWatch() {
+	std.StopWatch()
+}
+
+// StopWatch stops watching the config files.
+// It waits for a reload that's underway to finish.
+func (b *Binder) StopWatch() {
+	b.mu.Lock()
+	w := b.watcher
+	b.watcher = nil
+	b.mu.Unlock()
+	if w == nil {
+		return
+	}
+	close(w.done)
+	w.fsw.Close()
+	w.wg.Wait()
+}
+
+func (b *Binder) watch(w *watcher, files map[string]bool, fn func(ReloadEvent)) {
+	defer w.wg.Done()
+	var timer *time.Timer
+	var fire <-chan time.Time
+	changed := make(map[string]bool)
+	for {
+		select {
+		case <-w.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			name := absPath(ev.Name)
+			if !watching(files, name) || ev.Op == fsnotify.Chmod {
+				continue
+			}
+			if b.Debug() {
+				fmt.Printf("Config file changed: %s\n", ev)
+			}
+			changed[name] = true
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(WatchDelay)
+			fire = timer.C
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			if fn != nil {
+				fn(ReloadEvent{Err: err})
+			}
+		case <-fire:
+			timer, fire = nil, nil
+			var names []string
+			for n := range changed {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			changed = make(map[string]bool)
+
+			ev := b.reloadChanged(names)
+			// The files to look at may be different after a reload.
+			b.mu.Lock()
+			files = b.watchFiles()
+			b.mu.Unlock()
+			for _, d := range watchDirs(files) {
+				w.fsw.Add(d)
+			}
+			if fn != nil {
+				fn(ev)
+			}
+		}
+	}
+}
+
+// reloadChanged reloads the config and re-applies the bindings,
+// reporting on which keys changed.
+func (b *Binder) reloadChanged(files []string) ReloadEvent {
+	if b.Debug() {
+		pef()
+		defer pxf()
+	}
+	defer b.deliver()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.refresh()
+
+	ev := ReloadEvent{Files: files}
+	before := b.settings()
+	if ev.Err = b.loadChecked(true); ev.Err != nil {
+		return ev
+	}
+	if err := b.loadEnv(); err != nil && b.Debug() {
+		fmt.Printf("Reading the environment: %v\n", err)
+	}
+	b.apply()
+	if err := b.loadProfile(); err != nil && b.Debug() {
+		fmt.Printf("Switching profile: %v\n", err)
+	}
+	ev.Keys = b.changedSettings(before, b.settings())
+	if err := b.interpolate(); err != nil && b.Debug() {
+		fmt.Printf("Expanding references: %v\n", err)
+	}
+	return ev
+}
+
+// settings returns the value of each key viper knows about.
+// b.mu must be held.
+func (b *Binder) settings() map[string]interface{} {
+	v := b.Viper()
+	s := make(map[string]interface{})
+	for _, k := range v.AllKeys() {
+		s[k] = v.Get(k)
+	}
+	return s
+}
+
+// changedKeys returns the sorted keys that have a different value in after than in before.
+func changedKeys(before, after map[string]interface{}) (keys []string) {
+	for k, av := range after {
+		if bv, ok := before[k]; !ok || !reflect.DeepEqual(av, bv) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// watchFiles are the files that would change the config: those read and those
+// that were looked for before them. b.mu must be held.
+func (b *Binder) watchFiles() map[string]bool {
+	files := make(map[string]bool)
+	for _, f := range b.discovery.Candidates {
+		files[absPath(f)] = true
+	}
+	for _, l := range b.layers {
+		files[absPath(l.File)] = true
+	}
+	for _, f := range b.profileFiles {
+		files[absPath(f)] = true
+	}
+	for _, g := range b.includes {
+		files[g] = true
+	}
+	return files
+}
+
+// watching returns whether name is one of files, or matches one of the globs among them.
+func watching(files map[string]bool, name string) bool {
+	if files[name] {
+		return true
+	}
+	for f := range files {
+		if isGlob(f) {
+			if ok, _ := filepath.Match(f, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func watchDirs(files map[string]bool) (dirs []string) {
+	seen := make(map[string]bool)
+	for f := range files {
+		d := filepath.Dir(f)
+		if !seen[d] {
+			seen[d] = true
+			dirs = append(dirs, d)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+func absPath(p string) string {
+	if ap, err := filepath.Abs(p); err == nil {
+		return ap
+	}
+	return filepath.Clean(p)
+}
@@ -0,0 +1,87 @@
+package vconfig
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestWatch(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	defer func(d time.Duration) { WatchDelay = d }(WatchDelay)
+	WatchDelay = 20 * time.Millisecond
+
+	file := writeConfig(t, dir, "app.yaml", "screen: file\nconnection: one\nfilename: file-one\n")
+
+	// Setup
+	b := NewBinder(viper.New())
+	if err := b.Init(WithConfigFile(file), WithoutEnv()); err != nil {
+		t.Fatalf("Unexpected error from Init: %v", err)
+	}
+	pflags := pflag.NewFlagSet("Watch", pflag.ContinueOnError)
+	pflags.String("screen", "", "")
+	pflags.String("filename", "", "")
+	b.Bind("screen", pflags.Lookup("screen"))
+	b.Bind("filename", pflags.Lookup("filename"))
+	b.Set("screen", "set")
+
+	// A flag for one command, which shouldn't stop the file showing through afterwards.
+	pflags.Parse([]string{"--filename", "flag"})
+	b.ApplyFromFlags(pflags)
+	b.Apply()
+
+	events := make(chan ReloadEvent, 10)
+	if err := b.Watch(func(ev ReloadEvent) { events <- ev }); err != nil {
+		t.Fatalf("Unexpected error from Watch: %v", err)
+	}
+	defer b.StopWatch()
+
+	writeConfig(t, dir, "app.yaml", "screen: file-two\nconnection: two\nfilename: file-two\n")
+
+	var ev ReloadEvent
+	select {
+	case ev = <-events:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for a reload.")
+	}
+
+	// Tests
+	if ev.Err != nil {
+		t.Errorf("Unexpected error from reload: %v", ev.Err)
+	}
+	if e := []string{"connection", "filename"}; !reflect.DeepEqual(ev.Keys, e) {
+		t.Errorf("Wrong changed keys. Got: %#v, Expected: %#v", ev.Keys, e)
+	}
+	if len(ev.Files) != 1 || ev.Files[0] != absPath(file) {
+		t.Errorf("Wrong changed files. Got: %#v, Expected: %#v", ev.Files, file)
+	}
+	for k, e := range map[string]string{"screen": "set", "connection": "two", "filename": "file-two"} {
+		if v := b.Viper().GetString(k); v != e {
+			t.Errorf("Wrong value for %q after reload. Got: %#v, Expected: %#v", k, v, e)
+		}
+	}
+
+	// A bad file is reported and leaves things alone.
+	writeConfig(t, dir, "app.yaml", "a: 1\nb: 2\nc: 3: 4\n")
+	select {
+	case ev = <-events:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for a reload.")
+	}
+	if _, ok := ev.Err.(*ConfigParseError); !ok {
+		t.Errorf("Expected a ConfigParseError from the reload. Got: %#v", ev.Err)
+	}
+	if v := b.Viper().GetString("connection"); v != "two" {
+		t.Errorf("Failed reload changed a value. Got: %#v, Expected: %#v", v, "two")
+	}
+
+	b.StopWatch()
+	if err := b.Watch(nil); err != nil {
+		t.Errorf("Should be able to watch again after stopping: %v", err)
+	}
+}